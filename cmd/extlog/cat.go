@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/cipher"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tsisar/extended-log-go/logcrypt"
+)
+
+// runCat prints the given files to stdout, decrypting encrypted ones.
+func runCat(args []string) error {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	keyFlag := fs.String("key", "", "hex or base64 encryption key (default $"+logcrypt.EnvKey+")")
+	_ = fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return fmt.Errorf("cat: no files given")
+	}
+
+	var aead cipher.AEAD
+	for _, name := range fs.Args() {
+		if logcrypt.IsEncrypted(name) && aead == nil {
+			var err error
+			if aead, err = loadAEAD(*keyFlag); err != nil {
+				return err
+			}
+		}
+		if err := catFile(os.Stdout, name, aead); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAEAD builds the decryption cipher from the -key flag or the environment.
+func loadAEAD(keyFlag string) (cipher.AEAD, error) {
+	var provider logcrypt.KeyProvider = logcrypt.EnvKeyProvider(logcrypt.EnvKey)
+	if keyFlag != "" {
+		provider = logcrypt.KeyFunc(func() ([]byte, error) {
+			return logcrypt.ParseKey(keyFlag)
+		})
+	}
+	key, err := provider.Key()
+	if err != nil {
+		return nil, err
+	}
+	return logcrypt.NewAEAD(key)
+}
+
+func catFile(w io.Writer, name string, aead cipher.AEAD) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var r io.Reader = file
+	if logcrypt.IsEncrypted(name) {
+		r = logcrypt.NewReader(file, aead)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
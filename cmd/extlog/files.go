@@ -0,0 +1,116 @@
+package main
+
+import (
+	"cmp"
+	"crypto/cipher"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logcrypt"
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// logFile is a log file written by FileHandler.
+type logFile struct {
+	path string
+	date time.Time // the start of the period the file is for
+}
+
+// listLogFiles returns the files in dir named by the template names, plain or
+// encrypted, ordered by date.
+func listLogFiles(dir string, names *filename.Template, loc *time.Location) ([]logFile, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	var files []logFile
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// The current link points at a file that is listed already
+		if entry.IsDir() || entry.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if date, ok := names.Match(strings.TrimSuffix(rel, logcrypt.Extension), loc); ok {
+			files = append(files, logFile{path: path, date: date})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].date.Equal(files[j].date) {
+			return files[i].path < files[j].path
+		}
+		return files[i].date.Before(files[j].date)
+	})
+	return files, nil
+}
+
+// openLog opens a log file for reading, decrypting it when needed. The returned
+// reader wraps r, so callers can pass a following reader for encrypted files too.
+func openLog(name string, r io.Reader, keyFlag string) (io.Reader, error) {
+	if !logcrypt.IsEncrypted(name) {
+		return r, nil
+	}
+	aead, err := cachedAEAD(keyFlag)
+	if err != nil {
+		return nil, err
+	}
+	return logcrypt.NewReader(r, aead), nil
+}
+
+var aeadCache cipher.AEAD
+
+func cachedAEAD(keyFlag string) (cipher.AEAD, error) {
+	if aeadCache != nil {
+		return aeadCache, nil
+	}
+	aead, err := loadAEAD(keyFlag)
+	if err != nil {
+		return nil, err
+	}
+	aeadCache = aead
+	return aead, nil
+}
+
+// loadLocation returns the time zone log timestamps were written in.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return logparse.DefaultLocation(), nil
+	}
+	return time.LoadLocation(name)
+}
+
+// defaultFileName returns the file name template the log package uses with
+// the LOG_FILE_NAME, LOG_ROTATION and LOG_PROCESS_MODE of the environment.
+func defaultFileName() string {
+	interval, _ := filename.ParseRotation(os.Getenv("LOG_ROTATION"))
+	name := cmp.Or(os.Getenv("LOG_FILE_NAME"), filename.DefaultFor(interval))
+	// LOG_PROCESS_MODE=pid gives every process its own file
+	if os.Getenv("LOG_PROCESS_MODE") == "pid" {
+		return filename.WithPID(name)
+	}
+	return name
+}
+
+// defaultDirectory mirrors the directory used by the log package.
+func defaultDirectory() string {
+	if dir := os.Getenv("LOG_DIRECTORY"); dir != "" {
+		return dir
+	}
+	return "data/logs"
+}
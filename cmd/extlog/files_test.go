@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+)
+
+func TestDefaultFileName(t *testing.T) {
+	tests := []struct {
+		name, rotation, mode, want string
+	}{
+		{"", "", "", "{date}.log"},
+		{"", "hourly", "", "{time:2006-01-02T15}.log"},
+		{"", "15m", "", "{time:2006-01-02T15-04}.log"},
+		{"", "weekly", "", "{date}.log"},
+		{"", "soon", "", "{date}.log"},
+		{"{app}/{date}.log", "hourly", "", "{app}/{date}.log"},
+		{"", "hourly", "pid", "{time:2006-01-02T15}-{pid}.log"},
+	}
+	for _, tt := range tests {
+		t.Setenv("LOG_FILE_NAME", tt.name)
+		t.Setenv("LOG_ROTATION", tt.rotation)
+		t.Setenv("LOG_PROCESS_MODE", tt.mode)
+		if got := defaultFileName(); got != tt.want {
+			t.Errorf("LOG_FILE_NAME=%q LOG_ROTATION=%q LOG_PROCESS_MODE=%q: defaultFileName() = %s, want %s", tt.name, tt.rotation, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestListLogFiles_CustomHourlyName(t *testing.T) {
+	t.Setenv("LOG_FILE_NAME", "{app}/{time:2006-01-02T15}.log")
+	t.Setenv("LOG_ROTATION", "hourly")
+	t.Setenv("LOG_PROCESS_MODE", "")
+	dir := t.TempDir()
+	for _, name := range []string{"billing/2026-10-16T10.log.enc", "billing/2026-10-16T09.log", "orders/2026-10-16T08.log", "billing/notes.txt", "2026-10-16T11.log", "billing/2026-10-16.log"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runtime.GOOS != "windows" {
+		_ = os.Symlink(filepath.Join("billing", "2026-10-16T09.log"), filepath.Join(dir, "billing", "2026-10-16T12.log"))
+	}
+
+	names, err := filename.ParseAny(defaultFileName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := listLogFiles(dir, names, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"orders/2026-10-16T08.log", "billing/2026-10-16T09.log", "billing/2026-10-16T10.log.enc"}
+	if len(files) != len(want) {
+		t.Fatalf("listLogFiles() = %v, want %v", files, want)
+	}
+	for i, f := range files {
+		if rel, _ := filepath.Rel(dir, f.path); filepath.ToSlash(rel) != want[i] {
+			t.Errorf("file %d = %s, want %s", i, rel, want[i])
+		}
+		if hour := 8 + i; f.date.Hour() != hour {
+			t.Errorf("%s is for %v, want hour %d", f.path, f.date, hour)
+		}
+	}
+
+	if _, err := listLogFiles(filepath.Join(dir, "missing"), names, time.UTC); !os.IsNotExist(err) {
+		t.Errorf("a missing directory should be reported, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// filter selects entries by level, time range, caller and message pattern.
+type filter struct {
+	level   string
+	since   string
+	until   string
+	caller  string
+	pattern string
+
+	match   func(slog.Level) bool
+	loc     *time.Location
+	from    time.Time
+	to      time.Time
+	message *regexp.Regexp
+}
+
+// register adds the filter flags to fs.
+func (f *filter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.level, "level", "", "level filter: warn (at least warn), >=warn, >info, =error, <=debug")
+	fs.StringVar(&f.since, "since", "", "show entries newer than a duration (2h) or a time")
+	fs.StringVar(&f.until, "until", "", "show entries older than a duration (30m) or a time")
+	fs.StringVar(&f.caller, "caller", "", "show entries whose caller contains this string")
+}
+
+// compile validates the flag values. now is used to resolve relative durations.
+func (f *filter) compile(now time.Time, loc *time.Location) error {
+	f.loc = loc
+	f.match = func(slog.Level) bool { return true }
+	if f.level != "" {
+		match, err := parseLevelFilter(f.level)
+		if err != nil {
+			return err
+		}
+		f.match = match
+	}
+
+	var err error
+	if f.since != "" {
+		if f.from, err = parseTimeArg(f.since, now, loc); err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	if f.until != "" {
+		if f.to, err = parseTimeArg(f.until, now, loc); err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+	if f.pattern != "" {
+		if f.message, err = regexp.Compile(f.pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether e passes all filters.
+func (f *filter) matches(e logparse.Entry) bool {
+	if !f.match(e.Level) {
+		return false
+	}
+	if !f.from.IsZero() && e.Time.Before(f.from) {
+		return false
+	}
+	if !f.to.IsZero() && e.Time.After(f.to) {
+		return false
+	}
+	if f.caller != "" && !strings.Contains(e.Caller, f.caller) {
+		return false
+	}
+	if f.message != nil && !f.message.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+// parseLevelFilter parses expressions like "warn", ">=warn", "<info" or "=error".
+// A bare level name means "at least this level".
+func parseLevelFilter(expr string) (func(slog.Level) bool, error) {
+	expr = strings.TrimSpace(expr)
+	op := ">="
+	for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(expr, candidate) {
+			op = candidate
+			expr = expr[len(candidate):]
+			break
+		}
+	}
+	expr = strings.TrimSuffix(expr, "+") // "warn+" is the same as ">=warn"
+
+	level, err := logparse.ParseLevel(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case ">":
+		return func(l slog.Level) bool { return l > level }, nil
+	case "<":
+		return func(l slog.Level) bool { return l < level }, nil
+	case "<=":
+		return func(l slog.Level) bool { return l <= level }, nil
+	case "=":
+		return func(l slog.Level) bool { return l == level }, nil
+	default:
+		return func(l slog.Level) bool { return l >= level }, nil
+	}
+}
+
+// parseTimeArg accepts a duration relative to now or an absolute time.
+func parseTimeArg(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	layouts := []string{time.RFC3339, logparse.TimeLayout, "02.01.2006 15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a duration nor a time", s)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+func TestParseLevelFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []slog.Level // the matching levels of debug, info, warn and error
+	}{
+		{"warn", []slog.Level{slog.LevelWarn, slog.LevelError}},
+		{"warn+", []slog.Level{slog.LevelWarn, slog.LevelError}},
+		{">=info", []slog.Level{slog.LevelInfo, slog.LevelWarn, slog.LevelError}},
+		{">info", []slog.Level{slog.LevelWarn, slog.LevelError}},
+		{"=error", []slog.Level{slog.LevelError}},
+		{"<=info", []slog.Level{slog.LevelDebug, slog.LevelInfo}},
+		{"<warn", []slog.Level{slog.LevelDebug, slog.LevelInfo}},
+	}
+	for _, tt := range tests {
+		match, err := parseLevelFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseLevelFilter(%q) error: %v", tt.expr, err)
+			continue
+		}
+		var got []slog.Level
+		for _, l := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError} {
+			if match(l) {
+				got = append(got, l)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s matches %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+	if _, err := parseLevelFilter(">=loud"); err == nil {
+		t.Error("parseLevelFilter(>=loud) should fail")
+	}
+}
+
+func TestFilter_LevelAndTime(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	f := &filter{level: "warn", since: "2h", until: "2026-10-16 11:30", caller: "db.go"}
+	if err := f.compile(now, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if !f.from.Equal(now.Add(-2*time.Hour)) || !f.to.Equal(time.Date(2026, 10, 16, 11, 30, 0, 0, time.UTC)) {
+		t.Fatalf("range = %v to %v", f.from, f.to)
+	}
+
+	entry := func(ts string, level slog.Level, caller string) logparse.Entry {
+		tm, _ := time.Parse(time.DateTime, ts)
+		return logparse.Entry{Time: tm, Level: level, Caller: caller, Message: "m"}
+	}
+	tests := []struct {
+		e    logparse.Entry
+		want bool
+	}{
+		{entry("2026-10-16 11:00:00", slog.LevelWarn, "db.go:12"), true},
+		{entry("2026-10-16 11:00:00", slog.LevelInfo, "db.go:12"), false},
+		{entry("2026-10-16 09:59:59", slog.LevelError, "db.go:12"), false},
+		{entry("2026-10-16 11:30:01", slog.LevelError, "db.go:12"), false},
+		{entry("2026-10-16 11:00:00", slog.LevelError, "http.go:7"), false},
+	}
+	for _, tt := range tests {
+		if got := f.matches(tt.e); got != tt.want {
+			t.Errorf("matches(%v %v %s) = %v, want %v", tt.e.Time, tt.e.Level, tt.e.Caller, got, tt.want)
+		}
+	}
+
+	for _, bad := range []*filter{{level: "loud"}, {since: "yesterday"}, {until: "16/10/2026"}, {pattern: "("}} {
+		if err := bad.compile(now, time.UTC); err == nil {
+			t.Errorf("compile(%+v) should fail", *bad)
+		}
+	}
+}
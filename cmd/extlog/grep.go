@@ -0,0 +1,165 @@
+package main
+
+import (
+	"container/heap"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// runGrep prints matching entries from all log files merged in timestamp order.
+func runGrep(args []string) error {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	dir := fs.String("dir", defaultDirectory(), "log directory")
+	name := fs.String("name", defaultFileName(), "file name template of the logs (default $LOG_FILE_NAME)")
+	keyFlag := fs.String("key", "", "hex or base64 encryption key for .enc files")
+	tz := fs.String("tz", "", "time zone the logs were written in (default $LOG_TIMEZONE or local)")
+	output := fs.String("o", "text", "output format: text or json")
+	var f filter
+	f.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: extlog grep [flags] [PATTERN] [FILE...]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	loc, err := loadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		f.pattern = fs.Arg(0)
+	}
+	if err := f.compile(time.Now().In(loc), loc); err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	if fs.NArg() > 1 {
+		paths = fs.Args()[1:]
+	} else {
+		names, err := filename.ParseAny(*name)
+		if err != nil {
+			return fmt.Errorf("file name %q: %w", *name, err)
+		}
+		files, err := listLogFiles(*dir, names, loc)
+		if err != nil {
+			return err
+		}
+		for i, file := range files {
+			// A file holds the entries until the period of the next one starts
+			var end time.Time
+			for _, next := range files[i+1:] {
+				if next.date.After(file.date) {
+					end = next.date
+					break
+				}
+			}
+			if f.skipsPeriod(file.date, end) {
+				continue
+			}
+			paths = append(paths, file.path)
+		}
+	}
+
+	var sources []*source
+	defer func() {
+		for _, src := range sources {
+			_ = src.file.Close()
+		}
+	}()
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		r, err := openLog(path, file, *keyFlag)
+		if err != nil {
+			_ = file.Close()
+			return err
+		}
+		sources = append(sources, &source{name: path, file: file, scanner: logparse.NewScanner(r, loc)})
+	}
+
+	return merge(sources, func(e logparse.Entry) error {
+		if !f.matches(e) {
+			return nil
+		}
+		return p.print(e)
+	})
+}
+
+// skipsPeriod reports whether no entry written between start and end can
+// match the time range. A zero end is open.
+func (f *filter) skipsPeriod(start, end time.Time) bool {
+	if !f.from.IsZero() && !end.IsZero() && end.Before(f.from) {
+		return true
+	}
+	if !f.to.IsZero() && start.After(f.to) {
+		return true
+	}
+	return false
+}
+
+// source is one log file being merged.
+type source struct {
+	name    string
+	file    *os.File
+	scanner *logparse.Scanner
+	entry   logparse.Entry
+}
+
+// sourceHeap orders sources by the timestamp of their current entry.
+type sourceHeap []*source
+
+func (h sourceHeap) Len() int           { return len(h) }
+func (h sourceHeap) Less(i, j int) bool { return h[i].entry.Time.Before(h[j].entry.Time) }
+func (h sourceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *sourceHeap) Push(x any)        { *h = append(*h, x.(*source)) }
+func (h *sourceHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// merge calls fn for every entry of every source in timestamp order.
+func merge(sources []*source, fn func(logparse.Entry) error) error {
+	h := &sourceHeap{}
+	advance := func(src *source) error {
+		if src.scanner.Scan() {
+			src.entry = src.scanner.Entry()
+			heap.Push(h, src)
+			return nil
+		}
+		if err := src.scanner.Err(); err != nil {
+			return fmt.Errorf("%s: %w", src.name, err)
+		}
+		return nil
+	}
+
+	for _, src := range sources {
+		if err := advance(src); err != nil {
+			return err
+		}
+	}
+	for h.Len() > 0 {
+		src := heap.Pop(h).(*source)
+		if err := fn(src.entry); err != nil {
+			return err
+		}
+		if err := advance(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
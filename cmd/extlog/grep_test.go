@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+func TestMerge_TimeOrder(t *testing.T) {
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	files := map[string]string{
+		"api.log":    textLine(at(0), "INFO", "a0") + textLine(at(3), "INFO", "a3") + textLine(at(6), "INFO", "a6"),
+		"worker.log": textLine(at(1), "INFO", "w1") + textLine(at(2), "INFO", "w2") + "  > continued\n" + textLine(at(7), "INFO", "w7"),
+		"cron.log":   textLine(at(4), "INFO", "c4") + textLine(at(5), "INFO", "c5"),
+	}
+	var sources []*source
+	for name, data := range files {
+		sources = append(sources, &source{name: name, scanner: logparse.NewScanner(strings.NewReader(data), time.UTC)})
+	}
+
+	var got []string
+	err := merge(sources, func(e logparse.Entry) error {
+		got = append(got, e.Message)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("merge() error: %v", err)
+	}
+	if s := strings.Join(got, ","); s != "a0,w1,w2\ncontinued,a3,c4,c5,a6,w7" {
+		t.Errorf("merged %q", s)
+	}
+}
+
+func TestGrep_SkipsFilesOutsideTimeRange(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"2026-10-14.log", "2026-10-15.log", "2026-10-16.log"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, _ := filename.ParseAny(filename.Default)
+	files, err := listLogFiles(dir, names, time.UTC)
+	if err != nil || len(files) != 3 {
+		t.Fatalf("listLogFiles() = %v, %v", files, err)
+	}
+
+	f := &filter{since: "2026-10-15 12:00", until: "2026-10-15 18:00"}
+	if err := f.compile(time.Now(), time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	var kept []string
+	for i, file := range files {
+		var end time.Time
+		if i+1 < len(files) {
+			end = files[i+1].date
+		}
+		if !f.skipsPeriod(file.date, end) {
+			kept = append(kept, filepath.Base(file.path))
+		}
+	}
+	if len(kept) != 1 || kept[0] != "2026-10-15.log" {
+		t.Errorf("searched %v, want only 2026-10-15.log", kept)
+	}
+}
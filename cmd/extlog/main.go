@@ -0,0 +1,61 @@
+// Command extlog works with the log files written by the extended-log-go FileHandler.
+//
+// Usage:
+//
+//	extlog cat [-key KEY] FILE...
+//	extlog tail [-f] [-n N] [-dir DIR] [-name TEMPLATE] [filters] [PATTERN]
+//	extlog grep [-dir DIR] [-name TEMPLATE] [filters] [PATTERN] [FILE...]
+//
+// The files in the log directory are found with the LOG_FILE_NAME template,
+// which -name overrides, e.g. -name '{time:2006-01-02T15}.log' for hourly files.
+//
+// Filters are -level (warn, >=warn, =error, <info), -since and -until
+// (durations like 2h or absolute times) and -caller (file name substring).
+// Both tail and grep print text or, with -o json, one JSON object per line.
+//
+// Files ending in .enc are decrypted with the key given by -key or the
+// LOG_ENCRYPTION_KEY environment variable; plain files are copied as is.
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: extlog <command> [arguments]
+
+Commands:
+  cat, decrypt   print log files, decrypting encrypted ones
+  tail           show the newest entries, -f follows across file rotation
+  grep           search all log files, merged in timestamp order
+
+Run 'extlog <command> -h' for the flags of a command.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "cat", "decrypt":
+		err = runCat(os.Args[2:])
+	case "tail":
+		err = runTail(os.Args[2:])
+	case "grep":
+		err = runGrep(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "extlog: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "extlog: %v\n", err)
+		os.Exit(1)
+	}
+}
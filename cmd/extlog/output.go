@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// printer writes entries in the selected output format.
+type printer struct {
+	w      io.Writer
+	format string
+}
+
+func newPrinter(w io.Writer, format string) (*printer, error) {
+	switch format {
+	case "text", "json":
+		return &printer{w: w, format: format}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q (want text or json)", format)
+}
+
+func (p *printer) print(e logparse.Entry) error {
+	if p.format == "json" {
+		data, err := marshalEntry(e)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(p.w, "%s\n", data)
+		return err
+	}
+	_, err := fmt.Fprintln(p.w, e.String())
+	return err
+}
+
+// marshalEntry encodes an entry as a JSON object with the attributes after
+// the standard keys, in their original order.
+func marshalEntry(e logparse.Entry) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	if err := writeField(&buf, "time", e.Time.Format(time.RFC3339Nano)); err != nil {
+		return nil, err
+	}
+	if err := writeField(&buf, "level", logparse.LevelString(e.Level)); err != nil {
+		return nil, err
+	}
+	if e.Caller != "" {
+		if err := writeField(&buf, "caller", e.Caller); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeField(&buf, "msg", e.Message); err != nil {
+		return nil, err
+	}
+	for _, a := range e.Attrs {
+		if err := writeField(&buf, a.Key, a.Value.Any()); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// writeField appends "key":value to a JSON object being built in buf.
+func writeField(buf *bytes.Buffer, key string, value any) error {
+	k, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if buf.Len() > 1 {
+		buf.WriteByte(',')
+	}
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+	return nil
+}
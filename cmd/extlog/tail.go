@@ -0,0 +1,232 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logcrypt"
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// runTail prints the last entries of the newest log file and optionally
+// follows it, switching to the next file when the log is rotated.
+func runTail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ExitOnError)
+	dir := fs.String("dir", defaultDirectory(), "log directory")
+	name := fs.String("name", defaultFileName(), "file name template of the logs (default $LOG_FILE_NAME)")
+	follow := fs.Bool("f", false, "follow the log across file rotation")
+	lines := fs.Int("n", 10, "number of entries to show initially")
+	interval := fs.Duration("interval", 500*time.Millisecond, "poll interval when following")
+	keyFlag := fs.String("key", "", "hex or base64 encryption key for .enc files")
+	tz := fs.String("tz", "", "time zone the logs were written in (default $LOG_TIMEZONE or local)")
+	output := fs.String("o", "text", "output format: text or json")
+	var f filter
+	f.register(fs)
+	_ = fs.Parse(args)
+
+	loc, err := loadLocation(*tz)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		f.pattern = fs.Arg(0)
+	}
+	if err := f.compile(time.Now().In(loc), loc); err != nil {
+		return err
+	}
+	p, err := newPrinter(os.Stdout, *output)
+	if err != nil {
+		return err
+	}
+	names, err := filename.ParseAny(*name)
+	if err != nil {
+		return fmt.Errorf("file name %q: %w", *name, err)
+	}
+
+	t := &tailer{
+		dir:      *dir,
+		names:    names,
+		keyFlag:  *keyFlag,
+		loc:      loc,
+		filter:   &f,
+		printer:  p,
+		lines:    *lines,
+		follow:   *follow,
+		interval: *interval,
+	}
+	path, err := t.newestLogFile()
+	if err != nil {
+		return err
+	}
+	if path == "" && !*follow {
+		return fmt.Errorf("no log files named %s in %s", *name, *dir)
+	}
+	return t.run(path)
+}
+
+// newestLogFile returns the path of the most recent log file, or "" if there is none.
+func (t *tailer) newestLogFile() (string, error) {
+	files, err := listLogFiles(t.dir, t.names, t.loc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", nil
+	}
+	return files[len(files)-1].path, nil
+}
+
+// tailer prints the end of a log file and follows new files as they appear.
+type tailer struct {
+	dir      string
+	names    *filename.Template
+	keyFlag  string
+	loc      *time.Location
+	filter   *filter
+	printer  *printer
+	lines    int
+	follow   bool
+	interval time.Duration
+
+	live    bool
+	backlog []logparse.Entry
+}
+
+func (t *tailer) run(path string) error {
+	for {
+		for path == "" {
+			time.Sleep(t.interval)
+			var err error
+			if path, err = t.newestLogFile(); err != nil {
+				return err
+			}
+		}
+
+		if err := t.readFile(path); err != nil {
+			return err
+		}
+		if !t.follow {
+			return t.flushBacklog()
+		}
+
+		// The follow reader only stops after a newer file appeared
+		next, err := t.newestLogFile()
+		for err == nil && next == path {
+			time.Sleep(t.interval)
+			next, err = t.newestLogFile()
+		}
+		if err != nil {
+			return err
+		}
+		path = next
+	}
+}
+
+// readFile prints the entries of a single file. Until the end of the first
+// file is reached, entries are kept in a backlog so only the last ones are shown.
+// When following, the file is polled for new entries until a newer file appears.
+func (t *tailer) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	r, err := openLog(path, file, t.keyFlag)
+	if err != nil {
+		return err
+	}
+
+	follow := &followReader{r: r, follow: t.follow}
+	scanner := logparse.NewScanner(follow, t.loc)
+	scanner.Follow(t.follow)
+	for {
+		for scanner.Scan() {
+			if err := t.handle(scanner.Entry()); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil || !t.follow {
+			return err
+		}
+
+		if err := t.flushBacklog(); err != nil {
+			return err
+		}
+		if t.rotated(path) {
+			// Drain anything written between the last read and the rollover
+			follow.follow = false
+			scanner.Follow(false)
+			for scanner.Scan() {
+				if err := t.handle(scanner.Entry()); err != nil {
+					return err
+				}
+			}
+			return scanner.Err()
+		}
+		time.Sleep(t.interval)
+	}
+}
+
+// followReader reads a log file that is still being written. While following,
+// an encrypted segment that is not completely written yet is the end of the
+// available input rather than an error.
+type followReader struct {
+	r      io.Reader
+	follow bool
+}
+
+func (r *followReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if r.follow && errors.Is(err, logcrypt.ErrTruncated) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// handle prints or buffers a single entry.
+func (t *tailer) handle(e logparse.Entry) error {
+	if !t.filter.matches(e) {
+		return nil
+	}
+	if t.live {
+		return t.printer.print(e)
+	}
+	t.backlog = append(t.backlog, e)
+	if len(t.backlog) > t.lines {
+		t.backlog = t.backlog[1:]
+	}
+	return nil
+}
+
+// rotated reports whether a newer log file than path exists.
+func (t *tailer) rotated(path string) bool {
+	next, err := t.newestLogFile()
+	return err == nil && next != "" && filepath.Clean(next) != filepath.Clean(path)
+}
+
+// flushBacklog prints the buffered entries and switches to live output.
+func (t *tailer) flushBacklog() error {
+	if t.live {
+		return nil
+	}
+	t.live = true
+	for _, e := range t.backlog {
+		if err := t.printer.print(e); err != nil {
+			return err
+		}
+	}
+	t.backlog = nil
+	return nil
+}
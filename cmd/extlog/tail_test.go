@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logcrypt"
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// textLine returns an entry in the text format of the log package.
+func textLine(ts time.Time, level, msg string) string {
+	return fmt.Sprintf("%s | %-5s | %s\n", ts.Format(logparse.TimeLayout), level, msg)
+}
+
+func appendFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var errStopped = errors.New("stopped")
+
+// tailOutput collects the output of a tailer running in another goroutine
+// and stops it once a message containing stop is printed.
+type tailOutput struct {
+	mu   sync.Mutex
+	buf  strings.Builder
+	stop string
+}
+
+func (o *tailOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf.Write(p)
+	if strings.Contains(string(p), o.stop) {
+		return len(p), errStopped
+	}
+	return len(p), nil
+}
+
+func (o *tailOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// waitFor waits until the output contains s.
+func (o *tailOutput) waitFor(t *testing.T, s string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if strings.Contains(o.String(), s) {
+			return
+		}
+	}
+	t.Fatalf("output should contain %q, got %q", s, o.String())
+}
+
+func TestTailer_FollowsRollover(t *testing.T) {
+	dir := t.TempDir()
+	key := strings.Repeat("ab", 32)
+	aead, err := loadAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+
+	// The last entry of the first file is only partly written
+	first := filepath.Join(dir, "2026-10-16T10.log")
+	third := textLine(start.Add(3*time.Second), "INFO", "three")
+	appendFile(t, first, []byte(textLine(start.Add(time.Second), "INFO", "one")+textLine(start.Add(2*time.Second), "WARN", "two")+third[:len(third)-4]))
+
+	names, err := filename.ParseAny("{time:2006-01-02T15}.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &tailOutput{stop: "stop tailing"}
+	tl := &tailer{dir: dir, names: names, keyFlag: key, loc: time.UTC, filter: &filter{}, lines: 1, follow: true, interval: 5 * time.Millisecond}
+	tl.printer, _ = newPrinter(out, "text")
+	if err := tl.filter.compile(start, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	path, err := tl.newestLogFile()
+	if err != nil || path != first {
+		t.Fatalf("newestLogFile() = %s, %v", path, err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- tl.run(path) }()
+
+	out.waitFor(t, "two")
+	appendFile(t, first, []byte(third[len(third)-4:]))
+	out.waitFor(t, "three")
+
+	// The log rolls over to an encrypted file, its second segment arrives in two writes
+	second := filepath.Join(dir, "2026-10-16T11.log"+logcrypt.Extension)
+	var enc bytes.Buffer
+	w := logcrypt.NewWriter(&enc, aead)
+	_ = w.WriteHeader()
+	_, _ = w.Write([]byte(textLine(start.Add(time.Hour), "INFO", "four")))
+	appendFile(t, second, enc.Bytes())
+	out.waitFor(t, "four")
+
+	enc.Reset()
+	_, _ = w.Write([]byte(textLine(start.Add(time.Hour+time.Second), "ERROR", "five")))
+	segment := enc.Bytes()
+	appendFile(t, second, segment[:len(segment)/2])
+	time.Sleep(20 * time.Millisecond)
+	appendFile(t, second, segment[len(segment)/2:])
+	out.waitFor(t, "five")
+
+	enc.Reset()
+	_, _ = w.Write([]byte(textLine(start.Add(time.Hour+2*time.Second), "INFO", "stop tailing")))
+	appendFile(t, second, enc.Bytes())
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errStopped) {
+			t.Fatalf("run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tailer did not print the last entry")
+	}
+
+	var msgs []string
+	for e, err := range logparse.Entries(strings.NewReader(out.String()), time.UTC) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, e.Message)
+	}
+	if got := strings.Join(msgs, ","); got != "two,three,four,five,stop tailing" {
+		t.Errorf("printed %s, want the last entry of the backlog followed by every new one", got)
+	}
+}
+
+func TestTailer_Backlog(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	var data strings.Builder
+	for i, level := range []string{"INFO", "WARN", "DEBUG", "ERROR", "INFO"} {
+		data.WriteString(textLine(start.Add(time.Duration(i)*time.Second), level, fmt.Sprintf("entry %d", i)))
+	}
+	appendFile(t, filepath.Join(dir, "2026-10-16.log"), []byte(data.String()))
+
+	names, _ := filename.ParseAny(filename.Default)
+	var out bytes.Buffer
+	f := &filter{level: "warn"}
+	if err := f.compile(start, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	tl := &tailer{dir: dir, names: names, loc: time.UTC, filter: f, lines: 2}
+	tl.printer, _ = newPrinter(&out, "text")
+	path, _ := tl.newestLogFile()
+	if err := tl.run(path); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if got := out.String(); strings.Count(got, "\n") != 2 || !strings.Contains(got, "entry 1") || !strings.Contains(got, "entry 3") {
+		t.Errorf("output = %q, want the last two entries at warn or above", got)
+	}
+}
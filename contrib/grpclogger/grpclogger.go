@@ -0,0 +1,147 @@
+// Package grpclogger provides a grpclog.LoggerV2 that writes through the
+// handlers of the log package:
+//
+//	grpclog.SetLoggerV2(grpclogger.New())
+//
+// Verbosity checks with V are mapped with log.VerbosityLevel: V(0) is Info,
+// V(1) is Debug and V(2) and above are Trace.
+package grpclogger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tsisar/extended-log-go/log"
+	"google.golang.org/grpc/grpclog"
+)
+
+// Logger implements grpclog.LoggerV2 and grpclog.DepthLoggerV2.
+type Logger struct{}
+
+var (
+	_ grpclog.LoggerV2      = Logger{}
+	_ grpclog.DepthLoggerV2 = Logger{}
+)
+
+// New returns a Logger.
+func New() Logger {
+	return Logger{}
+}
+
+// output logs a message for the caller skip frames above output's caller.
+func output(skip int, level slog.Level, msg string) {
+	ctx := context.Background()
+	l := log.Logger()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(skip+2, pcs[:]) // skip: runtime.Callers, output
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// sprintln formats args like fmt.Println without the trailing newline.
+func sprintln(args []any) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
+
+// The plain methods are called by the grpclog package functions, so the
+// reported caller is two frames up: past the method and grpclog.Info etc.
+
+// Info logs at Info level.
+func (Logger) Info(args ...any) {
+	output(2, slog.LevelInfo, fmt.Sprint(args...))
+}
+
+// Infoln logs at Info level.
+func (Logger) Infoln(args ...any) {
+	output(2, slog.LevelInfo, sprintln(args))
+}
+
+// Infof logs a formatted message at Info level.
+func (Logger) Infof(format string, args ...any) {
+	output(2, slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+// Warning logs at Warn level.
+func (Logger) Warning(args ...any) {
+	output(2, slog.LevelWarn, fmt.Sprint(args...))
+}
+
+// Warningln logs at Warn level.
+func (Logger) Warningln(args ...any) {
+	output(2, slog.LevelWarn, sprintln(args))
+}
+
+// Warningf logs a formatted message at Warn level.
+func (Logger) Warningf(format string, args ...any) {
+	output(2, slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+// Error logs at Error level.
+func (Logger) Error(args ...any) {
+	output(2, slog.LevelError, fmt.Sprint(args...))
+}
+
+// Errorln logs at Error level.
+func (Logger) Errorln(args ...any) {
+	output(2, slog.LevelError, sprintln(args))
+}
+
+// Errorf logs a formatted message at Error level.
+func (Logger) Errorf(format string, args ...any) {
+	output(2, slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+// Fatal logs at Error level and exits the program.
+func (Logger) Fatal(args ...any) {
+	output(2, slog.LevelError, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+// Fatalln logs at Error level and exits the program.
+func (Logger) Fatalln(args ...any) {
+	output(2, slog.LevelError, sprintln(args))
+	os.Exit(1)
+}
+
+// Fatalf logs a formatted message at Error level and exits the program.
+func (Logger) Fatalf(format string, args ...any) {
+	output(2, slog.LevelError, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// V reports whether logging at verbosity l is enabled.
+func (Logger) V(l int) bool {
+	return log.Logger().Enabled(context.Background(), log.VerbosityLevel(l))
+}
+
+// The depth methods are called by grpclog.InfoDepth etc., where depth 0 means
+// the caller of that function.
+
+// InfoDepth logs at Info level for the caller at depth.
+func (Logger) InfoDepth(depth int, args ...any) {
+	output(depth+2, slog.LevelInfo, sprintln(args))
+}
+
+// WarningDepth logs at Warn level for the caller at depth.
+func (Logger) WarningDepth(depth int, args ...any) {
+	output(depth+2, slog.LevelWarn, sprintln(args))
+}
+
+// ErrorDepth logs at Error level for the caller at depth.
+func (Logger) ErrorDepth(depth int, args ...any) {
+	output(depth+2, slog.LevelError, sprintln(args))
+}
+
+// FatalDepth logs at Error level for the caller at depth and exits the program.
+func (Logger) FatalDepth(depth int, args ...any) {
+	output(depth+2, slog.LevelError, sprintln(args))
+	os.Exit(1)
+}
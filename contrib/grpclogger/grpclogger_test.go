@@ -0,0 +1,43 @@
+package grpclogger
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tsisar/extended-log-go/log"
+	"github.com/tsisar/extended-log-go/logtest"
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestLogger(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	grpclog.SetLoggerV2(New())
+
+	grpclog.Infof("dialing %s", "localhost:50051")
+	grpclog.Warningln("connection", "lost")
+	grpclog.Component("transport").Error("stream closed")
+
+	rec.HasEntry(slog.LevelInfo, "dialing localhost:50051")
+	rec.HasEntry(slog.LevelWarn, "connection lost")
+	rec.HasEntry(slog.LevelError, "[transport] stream closed")
+	for _, e := range rec.Entries() {
+		if !strings.HasPrefix(e.Caller, "grpclogger_test.go:") {
+			t.Errorf("%q: caller = %q, want grpclogger_test.go", e.Message, e.Caller)
+		}
+	}
+}
+
+func TestLogger_V(t *testing.T) {
+	orig := log.Logger()
+	defer log.SetLogger(orig)
+	log.SetLogger(slog.New(slog.NewTextHandler(nil, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	l := New()
+	if !l.V(0) || !l.V(1) {
+		t.Error("V(0) and V(1) should be enabled at Debug level")
+	}
+	if l.V(2) {
+		t.Error("V(2) maps to Trace and should be disabled at Debug level")
+	}
+}
@@ -0,0 +1,286 @@
+package grpclogger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/requestid"
+	"github.com/tsisar/extended-log-go/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// RequestIDMetadata is the metadata key whose value is added to the
+// request-scoped logger of server calls. IDs longer than 128 characters or
+// with characters other than letters, digits and "-_.:+/=" are replaced by a
+// new one, as is a missing ID.
+const RequestIDMetadata = "x-request-id"
+
+// Options configures the interceptors.
+type Options struct {
+	// PayloadSizes adds the total size in bytes of the request and response
+	// messages as request_bytes and response_bytes.
+	PayloadSizes bool
+	// SkipMethods are full method names, such as
+	// "/grpc.health.v1.Health/Check", that are not logged.
+	SkipMethods []string
+	// Level maps the status code of a finished call to a level.
+	// Defaults to CodeLevel.
+	Level func(codes.Code) slog.Level
+}
+
+// CodeLevel is the default mapping of status codes to levels: codes caused by
+// the caller are Info, transient or state-related failures are Warn and
+// server faults are Error.
+func CodeLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound,
+		codes.AlreadyExists, codes.Unauthenticated:
+		return slog.LevelInfo
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted,
+		codes.FailedPrecondition, codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+func (o Options) skip(method string) bool {
+	return slices.Contains(o.SkipMethods, method)
+}
+
+func (o Options) level(code codes.Code) slog.Level {
+	if o.Level != nil {
+		return o.Level(code)
+	}
+	return CodeLevel(code)
+}
+
+// call collects what is logged about a single call. The sizes are counted
+// atomically, since a stream may still send while the call is logged.
+type call struct {
+	opts          Options
+	logger        *slog.Logger
+	msg           string
+	peer          string
+	start         time.Time
+	requestBytes  atomic.Int64
+	responseBytes atomic.Int64
+}
+
+func (c *call) addSize(n *atomic.Int64, m any) {
+	if c.opts.PayloadSizes {
+		if pm, ok := m.(proto.Message); ok {
+			n.Add(int64(proto.Size(pm)))
+		}
+	}
+}
+
+func (c *call) log(ctx context.Context, err error) {
+	code := status.Code(err)
+	attrs := []slog.Attr{
+		slog.String("peer", c.peer),
+		slog.String("code", code.String()),
+		slog.Duration("duration", time.Since(c.start)),
+	}
+	if c.opts.PayloadSizes {
+		attrs = append(attrs, slog.Int64("request_bytes", c.requestBytes.Load()), slog.Int64("response_bytes", c.responseBytes.Load()))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("err", status.Convert(err).Message()))
+	}
+	c.logger.LogAttrs(ctx, c.opts.level(code), c.msg, attrs...)
+}
+
+// serverContext returns ctx with a request-scoped logger carrying the method
+// and the request ID from the incoming metadata or a new one.
+func serverContext(ctx context.Context, method string) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDMetadata); len(ids) > 0 {
+			id = ids[0]
+		}
+	}
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	l := log.FromContext(ctx).With(slog.String("method", method), slog.String("request_id", id))
+	return log.NewContext(ctx, l)
+}
+
+// newServerCall starts a call logged with the request-scoped logger of ctx.
+func newServerCall(ctx context.Context, opts Options) *call {
+	c := &call{opts: opts, logger: log.FromContext(ctx), msg: "grpc server call", start: time.Now()}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		c.peer = p.Addr.String()
+	}
+	return c
+}
+
+// UnaryServerInterceptor returns an interceptor logging every unary call and
+// placing a request-scoped logger in the handler's context.
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = serverContext(ctx, info.FullMethod)
+		if opts.skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		c := newServerCall(ctx, opts)
+		c.addSize(&c.requestBytes, req)
+		resp, err := handler(ctx, req)
+		c.addSize(&c.responseBytes, resp)
+		c.log(ctx, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor logging every streaming call
+// when it finishes and placing a request-scoped logger in the stream's context.
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := serverContext(ss.Context(), info.FullMethod)
+		if opts.skip(info.FullMethod) {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		}
+		c := newServerCall(ctx, opts)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx, call: c})
+		c.log(ctx, err)
+		return err
+	}
+}
+
+// serverStream replaces the context of a stream and counts message sizes.
+type serverStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	call *call
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil && s.call != nil {
+		s.call.addSize(&s.call.responseBytes, m)
+	}
+	return err
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && s.call != nil {
+		s.call.addSize(&s.call.requestBytes, m)
+	}
+	return err
+}
+
+// newClientCall starts a call logged with the logger of ctx.
+func newClientCall(ctx context.Context, opts Options, method string, cc *grpc.ClientConn) *call {
+	l := log.FromContext(ctx).With(slog.String("method", method))
+	return &call{opts: opts, logger: l, msg: "grpc client call", peer: cc.Target(), start: time.Now()}
+}
+
+// UnaryClientInterceptor returns an interceptor logging every unary call with
+// the logger from the call's context.
+func UnaryClientInterceptor(opts Options) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		if opts.skip(method) {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		c := newClientCall(ctx, opts, method, cc)
+		c.addSize(&c.requestBytes, req)
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		if err == nil {
+			c.addSize(&c.responseBytes, reply)
+		}
+		c.log(ctx, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor logging every streaming call
+// once the response stream ends or fails, the single response of a
+// client-streaming call is received, or the call's context is done.
+func StreamClientInterceptor(opts Options) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if opts.skip(method) {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+		c := newClientCall(ctx, opts, method, cc)
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			c.log(ctx, err)
+			return nil, err
+		}
+		s := &clientStream{ClientStream: cs, ctx: ctx, call: c, serverStreams: desc.ServerStreams, done: make(chan struct{})}
+		if ctx.Done() != nil {
+			// Streams the caller cancels or abandons are logged as well
+			go s.watch()
+		}
+		return s, nil
+	}
+}
+
+// clientStream counts message sizes and logs the call when receiving ends.
+type clientStream struct {
+	grpc.ClientStream
+	ctx           context.Context
+	call          *call
+	serverStreams bool
+	once          sync.Once
+	done          chan struct{}
+}
+
+// finish logs the call once.
+func (s *clientStream) finish(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.call.log(s.ctx, err)
+	})
+}
+
+// watch logs the call when its context is done before the call finished.
+func (s *clientStream) watch() {
+	select {
+	case <-s.ctx.Done():
+		s.finish(status.FromContextError(s.ctx.Err()).Err())
+	case <-s.done:
+	}
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.call.addSize(&s.call.requestBytes, m)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == nil:
+		s.call.addSize(&s.call.responseBytes, m)
+		if !s.serverStreams {
+			// The only response of a client-streaming call, e.g. CloseAndRecv
+			s.finish(nil)
+		}
+	case errors.Is(err, io.EOF):
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+package grpclogger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/log"
+	"github.com/tsisar/extended-log-go/logtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// checkServer wraps the health service to log through the request-scoped logger.
+type checkServer struct {
+	*health.Server
+}
+
+func (s checkServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	log.FromContext(ctx).Info("checking", "service", req.Service)
+	return s.Server.Check(ctx, req)
+}
+
+// collectDesc is a client-streaming service that counts the requests it
+// receives, reusing the health check messages.
+var collectDesc = grpc.ServiceDesc{
+	ServiceName: "test.Collector",
+	HandlerType: (*any)(nil),
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Collect",
+		ClientStreams: true,
+		Handler: func(_ any, stream grpc.ServerStream) error {
+			n := 0
+			for {
+				err := stream.RecvMsg(new(healthpb.HealthCheckRequest))
+				if errors.Is(err, io.EOF) {
+					return stream.SendMsg(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_ServingStatus(n)})
+				}
+				if err != nil {
+					return err
+				}
+				n++
+			}
+		},
+	}},
+}
+
+const collectMethod = "/test.Collector/Collect"
+
+func startServer(t *testing.T, opts Options) healthpb.HealthClient {
+	t.Helper()
+	return healthpb.NewHealthClient(startConn(t, opts))
+}
+
+func startConn(t *testing.T, opts Options) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(opts)),
+		grpc.StreamInterceptor(StreamServerInterceptor(opts)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, checkServer{hs})
+	srv.RegisterService(&collectDesc, struct{}{})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(opts)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(opts)),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestInterceptors_Unary(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	client := startServer(t, Options{PayloadSizes: true})
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDMetadata, "req-1")
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "orders"}); err != nil {
+		t.Fatalf("Check() error: %v", err)
+	}
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "billing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("Check() error = %v, want NotFound", err)
+	}
+
+	method := "/grpc.health.v1.Health/Check"
+	rec.HasEntry(slog.LevelInfo, "checking", "method", method, "request_id", "req-1", "service", "orders")
+	rec.HasEntry(slog.LevelInfo, "grpc server call", "method", method, "request_id", "req-1", "code", "OK",
+		"request_bytes", 8, "response_bytes", 2)
+	rec.HasEntry(slog.LevelInfo, "grpc client call", "method", method, "code", "OK", "peer", "passthrough:///bufnet")
+	rec.HasEntry(slog.LevelInfo, "grpc server call", "method", method, "code", "NotFound")
+}
+
+func TestInterceptors_InvalidRequestID(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	client := startServer(t, Options{})
+
+	for _, id := range []string{"", "req-1 | user=admin", strings.Repeat("a", 129)} {
+		rec.Reset()
+		ctx := context.Background()
+		if id != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, RequestIDMetadata, id)
+		}
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "orders"}); err != nil {
+			t.Fatalf("Check() error: %v", err)
+		}
+		var got string
+		for _, e := range rec.Entries() {
+			if v, ok := e.Attr("request_id"); ok && e.Message == "grpc server call" {
+				got = v.String()
+			}
+		}
+		if len(got) != 32 || got == id {
+			t.Errorf("request ID %q was logged as %q, want a new one", id, got)
+		}
+	}
+}
+
+func TestInterceptors_Stream(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	client := startServer(t, Options{Level: func(codes.Code) slog.Level { return slog.LevelWarn }})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "orders"})
+	if err != nil {
+		t.Fatalf("Watch() error: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv() error: %v", err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Fatalf("Recv() error = %v, want Canceled", err)
+	}
+
+	rec.HasEntry(slog.LevelWarn, "grpc client call", "method", "/grpc.health.v1.Health/Watch", "code", "Canceled")
+}
+
+func TestInterceptors_ClientStreaming(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	conn := startConn(t, Options{PayloadSizes: true})
+
+	stream, err := conn.NewStream(context.Background(), &collectDesc.Streams[0], collectMethod)
+	if err != nil {
+		t.Fatalf("NewStream() error: %v", err)
+	}
+	for range 3 {
+		if err := stream.SendMsg(&healthpb.HealthCheckRequest{Service: "orders"}); err != nil {
+			t.Fatalf("SendMsg() error: %v", err)
+		}
+	}
+	// What CloseAndRecv does; the response is the last message of the call
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("CloseSend() error: %v", err)
+	}
+	resp := new(healthpb.HealthCheckResponse)
+	if err := stream.RecvMsg(resp); err != nil || resp.Status != 3 {
+		t.Fatalf("RecvMsg() = %v, status %v", err, resp.Status)
+	}
+
+	rec.HasEntry(slog.LevelInfo, "grpc client call", "method", collectMethod, "code", "OK",
+		"request_bytes", 24, "response_bytes", 2)
+}
+
+func TestInterceptors_CanceledStream(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	conn := startConn(t, Options{})
+
+	// The caller gives up without receiving
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := conn.NewStream(ctx, &collectDesc.Streams[0], collectMethod)
+	if err != nil {
+		t.Fatalf("NewStream() error: %v", err)
+	}
+	_ = stream.SendMsg(&healthpb.HealthCheckRequest{Service: "orders"})
+	cancel()
+
+	m := logtest.Match(slog.LevelInfo, "grpc client call", "method", collectMethod, "code", "Canceled")
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, e := range rec.Entries() {
+			if m.Matches(e) {
+				return
+			}
+		}
+	}
+	t.Errorf("the canceled stream was not logged:\n%v", rec.Entries())
+}
+
+func TestInterceptors_SkipMethods(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	client := startServer(t, Options{SkipMethods: []string{"/grpc.health.v1.Health/Check"}})
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "orders"}); err != nil {
+		t.Fatalf("Check() error: %v", err)
+	}
+	for _, e := range rec.Entries() {
+		if strings.HasPrefix(e.Message, "grpc ") {
+			t.Errorf("skipped method should not be logged, got %s", e)
+		}
+	}
+	rec.HasEntry(slog.LevelInfo, "checking")
+}
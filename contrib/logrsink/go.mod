@@ -0,0 +1,10 @@
+module github.com/tsisar/extended-log-go/contrib/logrsink
+
+go 1.23
+
+require (
+	github.com/go-logr/logr v1.4.4
+	github.com/tsisar/extended-log-go v0.0.0
+)
+
+replace github.com/tsisar/extended-log-go => ../..
@@ -0,0 +1,94 @@
+// Package logrsink provides a logr.LogSink that writes through the handlers of
+// the log package, for libraries such as client-go and controller-runtime:
+//
+//	ctrl.SetLogger(logrsink.New())
+//
+// Verbosity is mapped with log.VerbosityLevel: V(0) is Info, V(1) is Debug and
+// V(2) and above are Trace.
+package logrsink
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/tsisar/extended-log-go/log"
+)
+
+// NameKey is the attribute holding the name set with logr.Logger.WithName.
+const NameKey = "logger"
+
+// Sink implements logr.LogSink and logr.CallDepthLogSink.
+type Sink struct {
+	name      string
+	values    []any
+	callDepth int
+}
+
+// New returns a logr.Logger backed by a Sink.
+func New() logr.Logger {
+	return logr.New(&Sink{})
+}
+
+// Init receives the call depth added by logr.Logger.
+func (s *Sink) Init(info logr.RuntimeInfo) {
+	s.callDepth = info.CallDepth
+}
+
+// Enabled reports whether records at the given verbosity are logged.
+func (s *Sink) Enabled(level int) bool {
+	return log.Logger().Enabled(context.Background(), log.VerbosityLevel(level))
+}
+
+// Info logs a message at the level mapped from the verbosity.
+func (s *Sink) Info(level int, msg string, keysAndValues ...any) {
+	s.log(log.VerbosityLevel(level), msg, keysAndValues)
+}
+
+// Error logs a message at Error level with err as the "err" attribute.
+func (s *Sink) Error(err error, msg string, keysAndValues ...any) {
+	s.log(slog.LevelError, msg, append([]any{"err", err}, keysAndValues...))
+}
+
+func (s *Sink) log(level slog.Level, msg string, keysAndValues []any) {
+	ctx := context.Background()
+	l := log.Logger()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3+s.callDepth, pcs[:]) // skip: runtime.Callers, log, Info or Error, then logr's frames
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	if s.name != "" {
+		r.AddAttrs(slog.String(NameKey, s.name))
+	}
+	r.Add(s.values...)
+	r.Add(keysAndValues...)
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// WithValues returns a sink adding keysAndValues to every record.
+func (s *Sink) WithValues(keysAndValues ...any) logr.LogSink {
+	s2 := *s
+	s2.values = append(append([]any(nil), s.values...), keysAndValues...)
+	return &s2
+}
+
+// WithName returns a sink with name appended to the logger name, separated by "/".
+func (s *Sink) WithName(name string) logr.LogSink {
+	s2 := *s
+	if s.name != "" {
+		name = s.name + "/" + name
+	}
+	s2.name = name
+	return &s2
+}
+
+// WithCallDepth returns a sink that reports a caller depth frames further up the stack.
+func (s *Sink) WithCallDepth(depth int) logr.LogSink {
+	s2 := *s
+	s2.callDepth += depth
+	return &s2
+}
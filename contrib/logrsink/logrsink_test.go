@@ -0,0 +1,46 @@
+package logrsink
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/tsisar/extended-log-go/log"
+	"github.com/tsisar/extended-log-go/logtest"
+)
+
+func TestSink_Levels(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	l := New().WithName("controller").WithName("pods").WithValues("namespace", "default")
+
+	l.Info("reconciled", "pod", "web-0")
+	l.V(1).Info("cache hit")
+	l.V(3).Info("raw object")
+	l.Error(errors.New("conflict"), "update failed")
+
+	rec.HasEntry(slog.LevelInfo, "reconciled", NameKey, "controller/pods", "namespace", "default", "pod", "web-0")
+	rec.HasEntry(slog.LevelDebug, "cache hit")
+	rec.HasEntry(log.LevelTrace, "raw object")
+	rec.HasEntry(slog.LevelError, "update failed", "err", "conflict")
+}
+
+func TestSink_Caller(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	l := New()
+
+	l.Info("direct")
+	logVia(l, "from helper")
+
+	for _, e := range rec.Entries() {
+		if !strings.HasPrefix(e.Caller, "logrsink_test.go:") {
+			t.Errorf("%q: caller = %q, want logrsink_test.go", e.Message, e.Caller)
+		}
+	}
+}
+
+// logVia reports its own caller by adding a call depth.
+func logVia(l logr.Logger, msg string) {
+	l.WithCallDepth(1).Info(msg)
+}
@@ -0,0 +1,99 @@
+// Package pgxlogger provides a pgx tracelog.Logger that writes through the
+// handlers of the log package:
+//
+//	cfg, _ := pgxpool.ParseConfig(url)
+//	cfg.ConnConfig.Tracer = pgxlogger.NewTracer()
+//
+// The same tracer works for database/sql connections opened through pgx's
+// stdlib package.
+package pgxlogger
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/tsisar/extended-log-go/log"
+)
+
+// Logger implements tracelog.Logger.
+type Logger struct{}
+
+// New returns a Logger.
+func New() Logger {
+	return Logger{}
+}
+
+// NewTracer returns a pgx tracer logging every query through a Logger. Which
+// records are written is decided by the configured log level.
+func NewTracer() *tracelog.TraceLog {
+	return &tracelog.TraceLog{Logger: New(), LogLevel: tracelog.LogLevelTrace}
+}
+
+// Log writes a pgx log message with data as attributes, sorted by key. The
+// caller is the first frame outside of pgx and database/sql, so records
+// point at the code that ran the query.
+func (Logger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+	l := log.Logger()
+	lvl := Level(level)
+	if !l.Enabled(ctx, lvl) {
+		return
+	}
+	r := slog.NewRecord(time.Now(), lvl, msg, callerPC())
+	for _, k := range slices.Sorted(maps.Keys(data)) {
+		r.AddAttrs(slog.Any(k, data[k]))
+	}
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// Level maps a pgx log level to a slog level.
+func Level(level tracelog.LogLevel) slog.Level {
+	switch level {
+	case tracelog.LogLevelTrace:
+		return log.LevelTrace
+	case tracelog.LogLevelDebug:
+		return slog.LevelDebug
+	case tracelog.LogLevelInfo:
+		return slog.LevelInfo
+	case tracelog.LogLevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// internalPrefixes are the packages skipped when looking for the caller.
+var internalPrefixes = []string{
+	"github.com/jackc/pgx/",
+	"database/sql.",
+}
+
+// callerPC returns the program counter of the first frame outside of pgx.
+func callerPC() uintptr {
+	var pcs [64]uintptr
+	n := runtime.Callers(3, pcs[:]) // skip: runtime.Callers, callerPC, Log
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !isInternal(f.Function) {
+			return f.PC
+		}
+		if !more {
+			return 0
+		}
+	}
+}
+
+func isInternal(function string) bool {
+	for _, p := range internalPrefixes {
+		if strings.HasPrefix(function, p) {
+			return true
+		}
+	}
+	return false
+}
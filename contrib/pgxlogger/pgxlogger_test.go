@@ -0,0 +1,67 @@
+package pgxlogger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/tracelog"
+	"github.com/tsisar/extended-log-go/log"
+	"github.com/tsisar/extended-log-go/logtest"
+)
+
+func TestLogger_Log(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+
+	New().Log(context.Background(), tracelog.LogLevelInfo, "Query", map[string]any{
+		"sql":  "select 1",
+		"time": "1ms",
+	})
+	New().Log(context.Background(), tracelog.LogLevelTrace, "Prepare", nil)
+
+	rec.HasEntry(slog.LevelInfo, "Query", "sql", "select 1", "time", "1ms")
+	rec.HasEntry(log.LevelTrace, "Prepare")
+	entries := rec.Entries()
+	if keys := entries[0].Attrs[0].Key + "," + entries[0].Attrs[1].Key; keys != "sql,time" {
+		t.Errorf("attributes should be sorted by key, got %s", keys)
+	}
+	if !strings.HasPrefix(entries[0].Caller, "pgxlogger_test.go:") {
+		t.Errorf("caller = %q, want pgxlogger_test.go", entries[0].Caller)
+	}
+}
+
+func TestTracer_Caller(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	tracer := NewTracer()
+	conn := &pgx.Conn{}
+
+	// pgx calls the tracer from its own frames, which are skipped
+	ctx := tracer.TraceQueryStart(context.Background(), conn, pgx.TraceQueryStartData{SQL: "select 1"})
+	_, _, line, _ := runtime.Caller(0)
+	tracer.TraceQueryEnd(ctx, conn, pgx.TraceQueryEndData{})
+
+	rec.HasEntry(slog.LevelInfo, "Query", "sql", "select 1")
+	entries := rec.Entries()
+	if want := fmt.Sprintf("pgxlogger_test.go:%d", line+1); len(entries) != 1 || entries[0].Caller != want {
+		t.Errorf("entries = %+v, want caller %s", entries, want)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	tests := map[tracelog.LogLevel]slog.Level{
+		tracelog.LogLevelTrace: log.LevelTrace,
+		tracelog.LogLevelDebug: slog.LevelDebug,
+		tracelog.LogLevelInfo:  slog.LevelInfo,
+		tracelog.LogLevelWarn:  slog.LevelWarn,
+		tracelog.LogLevelError: slog.LevelError,
+	}
+	for in, want := range tests {
+		if got := Level(in); got != want {
+			t.Errorf("Level(%v) = %v, want %v", in, got, want)
+		}
+	}
+}
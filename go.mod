@@ -1,3 +1,3 @@
 module github.com/tsisar/extended-log-go
 
-go 1.22
+go 1.23
@@ -0,0 +1,175 @@
+// Package httplog provides net/http middleware that writes an access log
+// record per request through the log package:
+//
+//	mux := http.NewServeMux()
+//	mux.HandleFunc("/orders", handleOrders)
+//	http.ListenAndServe(":8080", httplog.Middleware(httplog.Options{
+//		SkipPaths: []string{"/healthz"},
+//	})(mux))
+//
+// Handlers get a logger carrying the request ID with log.FromContext.
+package httplog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/requestid"
+	"github.com/tsisar/extended-log-go/log"
+)
+
+// RequestIDHeader is the default header carrying the request ID.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the attribute holding the request ID.
+const RequestIDKey = "request_id"
+
+// Options configures the middleware.
+type Options struct {
+	// Header carries the request ID of incoming requests and is set on
+	// responses. Defaults to RequestIDHeader. IDs longer than 128 characters
+	// or with characters other than letters, digits and "-_.:+/=" are
+	// replaced by a new one.
+	Header string
+	// SkipPaths are paths, such as health checks, that are not logged.
+	SkipPaths []string
+	// SampleSuccess logs only one in N requests with a status below 400.
+	// Zero or one logs every request. Errors are always logged.
+	SampleSuccess int
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the request ID stored in ctx by the middleware.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Middleware returns middleware that logs method, path, status, bytes,
+// duration, remote address and user agent of every request. The level is
+// Error for 5xx, Warn for 4xx and Info otherwise.
+func Middleware(opts Options) func(http.Handler) http.Handler {
+	header := opts.Header
+	if header == "" {
+		header = RequestIDHeader
+	}
+	var count atomic.Uint64
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !requestid.Valid(id) {
+				id = requestid.New()
+			}
+			w.Header().Set(header, id)
+
+			l := log.Logger().With(slog.String(RequestIDKey, id))
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			ctx = log.NewContext(ctx, l)
+
+			if slices.Contains(opts.SkipPaths, r.URL.Path) {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+			start := time.Now()
+			next.ServeHTTP(rw, r.WithContext(ctx))
+			duration := time.Since(start)
+
+			level := statusLevel(rw.status)
+			if level < slog.LevelWarn && opts.SampleSuccess > 1 && count.Add(1)%uint64(opts.SampleSuccess) != 1 {
+				return
+			}
+			l.LogAttrs(ctx, level, "http request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.status),
+				slog.Int64("bytes", rw.bytes),
+				slog.Duration("duration", duration),
+				slog.String("remote_addr", r.RemoteAddr),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
+// statusLevel returns the level for a response status code.
+func statusLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// responseWriter records the status code and the number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ReadFrom implements io.ReaderFrom, keeping the optimized copy of the
+// underlying writer if it has one.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.wroteHeader = true
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(w.ResponseWriter, r)
+	}
+	w.bytes += n
+	return n, err
+}
+
+// Hijack implements http.Hijacker if the underlying writer does, so that
+// connections can be upgraded, e.g. to WebSocket.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,146 @@
+package httplog
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tsisar/extended-log-go/log"
+	"github.com/tsisar/extended-log-go/logtest"
+)
+
+func TestMiddleware_LogsRequest(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	h := Middleware(Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.FromContext(r.Context()).Info("loading order")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("not found"))
+	}))
+
+	req := httptest.NewRequest("GET", "/orders/7", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("User-Agent", "curl/8.0")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if got := resp.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("response request ID = %q, want req-1", got)
+	}
+	rec.HasEntry(slog.LevelInfo, "loading order", RequestIDKey, "req-1")
+	rec.HasEntry(slog.LevelWarn, "http request",
+		RequestIDKey, "req-1", "method", "GET", "path", "/orders/7",
+		"status", 404, "bytes", 9, "user_agent", "curl/8.0", "remote_addr", req.RemoteAddr)
+}
+
+func TestMiddleware_GeneratesRequestID(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	var id string
+	h := Middleware(Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = RequestID(r.Context())
+	}))
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", "/", nil))
+
+	if len(id) != 32 || resp.Header().Get(RequestIDHeader) != id {
+		t.Errorf("request ID = %q, response header = %q", id, resp.Header().Get(RequestIDHeader))
+	}
+	rec.HasEntry(slog.LevelInfo, "http request", RequestIDKey, id, "status", 200)
+}
+
+func TestMiddleware_SkipAndSample(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	status := http.StatusOK
+	h := Middleware(Options{SkipPaths: []string{"/healthz"}, SampleSuccess: 3})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	for range 6 {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ok", nil))
+	}
+	status = http.StatusInternalServerError
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	var paths []string
+	for _, e := range rec.Entries() {
+		path, _ := e.Attr("path")
+		paths = append(paths, path.String())
+	}
+	if len(paths) != 3 || paths[0] != "/ok" || paths[1] != "/ok" || paths[2] != "/fail" {
+		t.Errorf("logged paths = %v, want [/ok /ok /fail]", paths)
+	}
+	rec.HasEntry(slog.LevelError, "http request", "path", "/fail", "status", 500)
+}
+
+func TestMiddleware_RejectsInvalidRequestID(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	h := Middleware(Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, id := range []string{"abc def", "id\x1b[31m", "a|b=c", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, id)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		if got := resp.Header().Get(RequestIDHeader); got == id || len(got) != 32 {
+			t.Errorf("request ID %q was replaced by %q", id, got)
+		}
+	}
+	for _, e := range rec.Entries() {
+		if v, _ := e.Attr(RequestIDKey); len(v.String()) != 32 {
+			t.Errorf("logged request ID %q", v)
+		}
+	}
+}
+
+func TestMiddleware_Hijack(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	done := make(chan struct{})
+	h := Middleware(Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("the middleware should keep http.Hijacker")
+			return
+		}
+		conn, buf, err := hj.Hijack()
+		if err != nil {
+			t.Errorf("Hijack() error: %v", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		_ = buf.Flush()
+	}))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+	<-done
+	rec.HasEntry(slog.LevelInfo, "http request", "path", "/")
+}
+
+func TestResponseWriter_ReadFrom(t *testing.T) {
+	resp := httptest.NewRecorder()
+	rw := &responseWriter{ResponseWriter: resp, status: http.StatusOK}
+	var w io.Writer = rw
+	if _, ok := w.(io.ReaderFrom); !ok {
+		t.Fatal("responseWriter should implement io.ReaderFrom")
+	}
+	n, err := rw.ReadFrom(strings.NewReader("body"))
+	if n != 4 || err != nil || rw.bytes != 4 || resp.Body.String() != "body" {
+		t.Errorf("ReadFrom() = %d, %v; bytes = %d, body = %q", n, err, rw.bytes, resp.Body.String())
+	}
+}
@@ -0,0 +1,278 @@
+// Package filename parses the templates naming log files, such as
+// LOG_FILE_NAME. It is shared by the log package, which writes and cleans up
+// the files, and extlog, which reads them.
+package filename
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default is the file name template used when LOG_FILE_NAME is not set.
+const Default = "{date}.log"
+
+// Rotation intervals with their own names in LOG_ROTATION.
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// timeTokens are the placeholders replaced by the time the file is for.
+var timeTokens = map[string]string{
+	"date":   "2006-01-02",
+	"year":   "2006",
+	"month":  "01",
+	"day":    "02",
+	"hour":   "15",
+	"minute": "04",
+}
+
+// Template is a parsed file name template such as
+// "{year}/{month}/{app}-{date}.log". It expands to the path of the log file
+// for a point in time and recognizes the files it produced.
+type Template struct {
+	parts   []part
+	pattern *regexp.Regexp
+	layout  string // the time layouts of the parts, separated by spaces
+}
+
+// part is either literal text or a time layout.
+type part struct {
+	text   string
+	layout string
+}
+
+// Parse parses a file name template. The placeholders are {date}, {year},
+// {month}, {day}, {hour}, {minute}, {time:LAYOUT} with a numeric Go time
+// layout, {app}, {host} and {pid}. Slashes create subdirectories. {app} is
+// replaced by app and {host} by the host name; {pid} is replaced by the
+// process ID but matches the files of every process.
+func Parse(s, app string) (*Template, error) {
+	return parse(s, app, false)
+}
+
+// ParseAny parses a file name template like Parse for reading the files of
+// any application: {app}, {host} and {pid} match any name.
+func ParseAny(s string) (*Template, error) {
+	return parse(s, "", true)
+}
+
+func parse(s, app string, anyName bool) (*Template, error) {
+	if s == "" || path.IsAbs(s) || strings.Contains(s, `\`) {
+		return nil, errors.New("must be a relative path with / separators")
+	}
+	for _, elem := range strings.Split(s, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return nil, errors.New("must be a relative path with / separators")
+		}
+	}
+
+	t := &Template{}
+	var pattern strings.Builder
+	var layouts []string
+	pattern.WriteString("^")
+	for rest := s; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			t.parts = append(t.parts, part{text: rest})
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		if start > 0 {
+			t.parts = append(t.parts, part{text: rest[:start]})
+			pattern.WriteString(regexp.QuoteMeta(rest[:start]))
+		}
+		end := strings.IndexByte(rest[start:], '}')
+		if end < 0 {
+			return nil, errors.New("unterminated placeholder")
+		}
+		token := rest[start+1 : start+end]
+		rest = rest[start+end+1:]
+
+		layout, isTime := timeTokens[token]
+		if l, ok := strings.CutPrefix(token, "time:"); ok {
+			layout, isTime = l, true
+		}
+		if isTime {
+			re, err := layoutPattern(layout)
+			if err != nil {
+				return nil, err
+			}
+			t.parts = append(t.parts, part{layout: layout})
+			pattern.WriteString("(" + re + ")")
+			layouts = append(layouts, layout)
+			continue
+		}
+
+		var value, re string
+		switch {
+		case token == "app" && anyName, token == "host" && anyName:
+			re = `[^/]+`
+		case token == "app":
+			if err := CheckAppName(app); err != nil {
+				return nil, err
+			}
+			value = app
+			re = regexp.QuoteMeta(app)
+		case token == "host":
+			value, _ = os.Hostname()
+			re = regexp.QuoteMeta(value)
+		case token == "pid":
+			// Files of earlier processes are cleaned up as well
+			value = strconv.Itoa(os.Getpid())
+			re = `\d+`
+		default:
+			return nil, fmt.Errorf("unknown placeholder {%s}", token)
+		}
+		t.parts = append(t.parts, part{text: value})
+		pattern.WriteString(re)
+	}
+	if len(layouts) == 0 {
+		return nil, errors.New("must contain a date placeholder")
+	}
+	pattern.WriteString("$")
+
+	t.pattern = regexp.MustCompile(pattern.String())
+	t.layout = strings.Join(layouts, " ")
+	return t, nil
+}
+
+// layoutPattern returns a regular expression matching times formatted with a
+// numeric layout such as "2006-01-02T15".
+func layoutPattern(layout string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(layout); {
+		rest := layout[i:]
+		switch {
+		case strings.HasPrefix(rest, "2006"):
+			b.WriteString(`\d{4}`)
+			i += 4
+		case hasAnyPrefix(rest, "01", "02", "15", "04", "05"):
+			b.WriteString(`\d{2}`)
+			i += 2
+		case rest[0] >= '0' && rest[0] <= '9', hasAnyPrefix(rest, "Jan", "Mon", "MST", "PM", "pm", "_2"):
+			return "", fmt.Errorf("unsupported time layout %q: use 2006, 01, 02, 15, 04 and 05", layout)
+		default:
+			b.WriteString(regexp.QuoteMeta(rest[:1]))
+			i++
+		}
+	}
+	if b.Len() == 0 {
+		return "", errors.New("empty time layout")
+	}
+	return b.String(), nil
+}
+
+func hasAnyPrefix(s string, prefixes ...string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand returns the relative path of the log file for now.
+func (t *Template) Expand(now time.Time) string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.layout != "" {
+			b.WriteString(now.Format(p.layout))
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	return filepath.FromSlash(b.String())
+}
+
+// Match reports whether rel, a path relative to the log directory, was
+// produced by the template and returns the time the file is for.
+func (t *Template) Match(rel string, loc *time.Location) (time.Time, bool) {
+	m := t.pattern.FindStringSubmatch(filepath.ToSlash(rel))
+	if m == nil {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation(t.layout, strings.Join(m[1:], " "), loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// Resolution returns the shortest period the template gives its own file:
+// a minute, an hour or a day.
+func (t *Template) Resolution() time.Duration {
+	switch {
+	case strings.Contains(t.layout, "04"):
+		return time.Minute
+	case strings.Contains(t.layout, "15"):
+		return time.Hour
+	}
+	return day
+}
+
+// CheckAppName reports an error if name, substituted for {app}, could place
+// log files outside the log directory.
+func CheckAppName(name string) error {
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errors.New("must be a single file name element without separators")
+	}
+	return nil
+}
+
+// ParseRotation parses LOG_ROTATION: daily, hourly, weekly or an interval
+// between one minute and one day such as 15m or 6h.
+func ParseRotation(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "daily", "1d":
+		return day, nil
+	case "hourly":
+		return time.Hour, nil
+	case "weekly", "1w", "7d":
+		return week, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || (d != week && (d < time.Minute || d > day)) || d%time.Minute != 0 {
+		return 0, errors.New("must be daily, hourly, weekly or whole minutes up to 24h")
+	}
+	return d, nil
+}
+
+// DefaultFor returns the file name template for a rotation interval that
+// gives every period its own file.
+func DefaultFor(interval time.Duration) string {
+	switch {
+	case interval <= 0 || interval%day == 0:
+		return Default
+	case interval%time.Hour == 0:
+		return "{time:2006-01-02T15}.log"
+	}
+	return "{time:2006-01-02T15-04}.log"
+}
+
+// WithPID adds {pid} to a file name template that does not contain it,
+// before the extension: {date}.log becomes {date}-{pid}.log.
+func WithPID(name string) string {
+	if strings.Contains(name, "{pid}") {
+		return name
+	}
+	return InsertBeforeExt(name, "-{pid}")
+}
+
+// InsertBeforeExt inserts s into a file name template before the extension:
+// {date}.log becomes {date}<s>.log.
+func InsertBeforeExt(name, s string) string {
+	i := strings.LastIndexByte(name, '.')
+	if i < strings.LastIndexByte(name, '}') || i < strings.LastIndexByte(name, '/') {
+		return name + s
+	}
+	return name[:i] + s + name[i:]
+}
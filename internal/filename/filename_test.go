@@ -0,0 +1,140 @@
+package filename
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	host, _ := os.Hostname()
+	now := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		template, want string
+	}{
+		{"{date}.log", "2026-10-16.log"},
+		{"{time:2006-01-02T15}.log", "2026-10-16T09.log"},
+		{"{year}/{month}/{day}/{app}.log", "2026/10/16/billing.log"},
+		{"{app}-{host}-{pid}-{date}.log", "billing-" + host + "-" + strconv.Itoa(os.Getpid()) + "-2026-10-16.log"},
+	}
+	for _, tt := range tests {
+		tmpl, err := Parse(tt.template, "billing")
+		if err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		got := tmpl.Expand(now)
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("%s: Expand() = %q, want %q", tt.template, got, tt.want)
+		}
+		date, ok := tmpl.Match(got, time.UTC)
+		if !ok || date.After(now) || now.Sub(date) >= 24*time.Hour {
+			t.Errorf("%s: Match(%q) = %v, %v", tt.template, got, date, ok)
+		}
+	}
+
+	tmpl, _ := Parse("{app}-{pid}-{date}.log", "billing")
+	if _, ok := tmpl.Match("billing-12345-2026-10-01.log", time.UTC); !ok {
+		t.Error("files of other processes should match")
+	}
+	for _, name := range []string{"orders-1-2026-10-01.log", "billing-1-2026-10-01.log.bak", "notes.txt"} {
+		if _, ok := tmpl.Match(name, time.UTC); ok {
+			t.Errorf("%s should not match", name)
+		}
+	}
+}
+
+func TestParseAny(t *testing.T) {
+	tmpl, err := ParseAny("{year}/{app}-{host}-{pid}-{date}.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, ok := tmpl.Match("2026/billing-web1-42-2026-10-16.log", time.UTC)
+	if !ok || !date.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Match() = %v, %v", date, ok)
+	}
+	if _, ok := tmpl.Match("2026/sub/billing-web1-42-2026-10-16.log", time.UTC); ok {
+		t.Error("{app} should not match across directories")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, s := range []string{"app.log", "/var/log/{date}.log", "../{date}.log", "{date", "{level}-{date}.log", "{time:Jan 2}.log", "{time:}.log"} {
+		if _, err := Parse(s, "app"); err == nil {
+			t.Errorf("Parse(%q) should fail", s)
+		}
+	}
+}
+
+func TestParse_InvalidAppName(t *testing.T) {
+	for _, app := range []string{"../../x", "a/b", `a\b`, "..", "."} {
+		if _, err := Parse("{app}-{date}.log", app); err == nil {
+			t.Errorf("{app} = %q should be rejected", app)
+		}
+	}
+	if _, err := Parse("{date}.log", "../x"); err != nil {
+		t.Errorf("an unused app name should not matter: %v", err)
+	}
+}
+
+func TestParseRotation(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"daily", day},
+		{"Hourly", time.Hour},
+		{"weekly", week},
+		{"15m", 15 * time.Minute},
+		{"6h", 6 * time.Hour},
+		{"1d", day},
+		{"1w", week},
+	}
+	for _, tt := range tests {
+		got, err := ParseRotation(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRotation(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{"", "monthly", "30s", "90s", "2d", "-1h"} {
+		if _, err := ParseRotation(s); err == nil {
+			t.Errorf("ParseRotation(%q) should fail", s)
+		}
+	}
+}
+
+func TestDefaultFor(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                Default,
+		day:              Default,
+		week:             Default,
+		time.Hour:        "{time:2006-01-02T15}.log",
+		6 * time.Hour:    "{time:2006-01-02T15}.log",
+		15 * time.Minute: "{time:2006-01-02T15-04}.log",
+	}
+	for interval, want := range tests {
+		if got := DefaultFor(interval); got != want {
+			t.Errorf("DefaultFor(%v) = %s, want %s", interval, got, want)
+		}
+		if tmpl, _ := Parse(DefaultFor(interval), ""); interval > 0 && interval < day && tmpl.Resolution() > interval {
+			t.Errorf("%s gives %v periods no file of their own", want, interval)
+		}
+	}
+}
+
+func TestWithPID(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"{date}.log", "{date}-{pid}.log"},
+		{"{year}/{month}/{app}-{date}.log", "{year}/{month}/{app}-{date}-{pid}.log"},
+		{"{time:2006.01.02}", "{time:2006.01.02}-{pid}"},
+		{"logs.d/{date}", "logs.d/{date}-{pid}"},
+		{"{pid}/{date}.log", "{pid}/{date}.log"},
+	}
+	for _, tt := range tests {
+		if got := WithPID(tt.name); got != tt.want {
+			t.Errorf("WithPID(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Package requestid checks request IDs sent by clients and generates new
+// ones. It is shared by httplog and the gRPC interceptors of grpclogger.
+package requestid
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// MaxLength limits the length of request IDs taken from clients.
+const MaxLength = 128
+
+// Valid reports whether a request ID sent by a client can be used in logs
+// and responses as is: at most MaxLength letters, digits and "-_.:+/=".
+func Valid(id string) bool {
+	if id == "" || len(id) > MaxLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-_.:+/=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// New returns 16 random bytes in hex.
+func New() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,297 @@
+package log
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// manifestName is the file in the log directory listing the archived log files.
+const manifestName = ".archive-manifest.jsonl"
+
+// archiveAttempts is how often archiving a file is tried before it is left
+// for the next cleanup.
+const archiveAttempts = 3
+
+// archiveTimeout limits a single attempt to archive a file.
+const archiveTimeout = 5 * time.Minute
+
+// Archiver stores rotated log files elsewhere before the retention policies
+// remove them. Archive is called from the goroutine cleaning up old files,
+// once for every file of a past rotation period that is not in the manifest
+// yet. A file is only removed after it was archived. The context of an
+// attempt ends after five minutes or when the file handler is closed.
+type Archiver interface {
+	// Archive stores the file and returns where it was stored.
+	Archive(ctx context.Context, f ArchiveFile) (location string, err error)
+}
+
+// ArchiveFile is a rotated log file to archive.
+type ArchiveFile struct {
+	Path string    // the file on disk
+	Name string    // the path relative to the log directory with / separators
+	Time time.Time // the start of the period the file is for
+	Size int64
+}
+
+var (
+	archiver   Archiver
+	archiverMu sync.Mutex
+)
+
+// SetArchiver installs a to archive rotated log files; nil disables
+// archiving. It applies from the next rotation on.
+func SetArchiver(a Archiver) {
+	archiverMu.Lock()
+	defer archiverMu.Unlock()
+	archiver = a
+}
+
+func currentArchiver() Archiver {
+	archiverMu.Lock()
+	defer archiverMu.Unlock()
+	return archiver
+}
+
+// manifestEntry is a line of the manifest.
+type manifestEntry struct {
+	File       string    `json:"file"`
+	Size       int64     `json:"size"`
+	SHA256     string    `json:"sha256"`
+	Location   string    `json:"location"`
+	ArchivedAt time.Time `json:"archived_at"`
+}
+
+// readManifest returns the entries of the manifest in dir by file name.
+func readManifest(dir string) map[string]manifestEntry {
+	entries := make(map[string]manifestEntry)
+	f, err := os.Open(filepath.Join(dir, manifestName))
+	if err != nil {
+		return entries
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e manifestEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries[e.File] = e
+		}
+	}
+	return entries
+}
+
+// appendManifest adds an entry to the manifest in dir.
+func appendManifest(dir string, e manifestEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, manifestName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// archived reports whether f is in the manifest with its current size.
+func archived(manifest map[string]manifestEntry, f ArchiveFile) bool {
+	e, ok := manifest[f.Name]
+	return ok && e.Size == f.Size
+}
+
+// archiveFile archives f, retrying with increasing delays, and records it in
+// the manifest. It gives up when ctx is done.
+func (p *retentionPolicy) archiveFile(ctx context.Context, f ArchiveFile) (manifestEntry, error) {
+	sum, err := fileSHA256(f.Path)
+	if err != nil {
+		return manifestEntry{}, err
+	}
+	var location string
+	delay := minRetryDelay
+	for attempt := 1; ; attempt++ {
+		location, err = p.archiveAttempt(ctx, f)
+		if err == nil || attempt == archiveAttempts {
+			break
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return manifestEntry{}, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+	if err != nil {
+		return manifestEntry{}, fmt.Errorf("archive %s: %w", f.Name, err)
+	}
+	e := manifestEntry{File: f.Name, Size: f.Size, SHA256: sum, Location: location, ArchivedAt: time.Now().UTC()}
+	return e, appendManifest(p.basePath, e)
+}
+
+// archiveAttempt calls the archiver once with a context limited to archiveTimeout.
+func (p *retentionPolicy) archiveAttempt(ctx context.Context, f ArchiveFile) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+	return p.archiver.Archive(ctx, f)
+}
+
+func fileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DirArchiver archives log files into one tar.gz file per month in a local
+// directory, e.g. 2026-10.tar.gz.
+type DirArchiver struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDirArchiver returns an archiver writing to dir, which is created if needed.
+func NewDirArchiver(dir string) *DirArchiver {
+	return &DirArchiver{dir: dir}
+}
+
+// Archive adds the file to the archive of its month, replacing an earlier
+// copy. The archive is rewritten and replaced atomically, so it stays
+// readable if the process stops while archiving.
+func (a *DirArchiver) Archive(ctx context.Context, f ArchiveFile) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(a.dir, defaultDirMode); err != nil {
+		return "", err
+	}
+	name := filepath.Join(a.dir, f.Time.Format("2006-01")+".tar.gz")
+	tmp, err := os.CreateTemp(a.dir, ".archive-*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := a.write(ctx, tmp, name, f); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return "", err
+	}
+	return name + "#" + path.Clean(f.Name), nil
+}
+
+// write writes the entries of the existing archive name, except an earlier
+// copy of f, followed by f to w. It stops when ctx is done.
+func (a *DirArchiver) write(ctx context.Context, w io.Writer, name string, f ArchiveFile) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	if err := copyArchive(ctx, tw, name, f.Name); err != nil {
+		return err
+	}
+
+	src, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    path.Clean(f.Name),
+		Mode:    0o644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Format:  tar.FormatPAX,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, ctxReader{ctx: ctx, r: src}); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// copyArchive copies the entries of the tar.gz file name, if it exists, to tw
+// except the one named skip.
+func copyArchive(ctx context.Context, tw *tar.Writer, name, skip string) error {
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(ctxReader{ctx: ctx, r: f})
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if hdr.Name == path.Clean(skip) {
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// ctxReader is a reader that fails once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
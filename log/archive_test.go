@@ -0,0 +1,235 @@
+package log
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tarContents returns the entries of a tar.gz file by name.
+func tarContents(t *testing.T, name string) map[string]string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	entries := make(map[string]string)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		entries[hdr.Name] = string(data)
+	}
+}
+
+func TestDirArchiver(t *testing.T) {
+	logs, archive := t.TempDir(), t.TempDir()
+	a := NewDirArchiver(archive)
+	archiveFile := func(name, content string, date time.Time) string {
+		t.Helper()
+		path := filepath.Join(logs, name)
+		if err := os.WriteFile(path, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		location, err := a.Archive(context.Background(), ArchiveFile{Path: path, Name: name, Time: date, Size: int64(len(content))})
+		if err != nil {
+			t.Fatalf("Archive(%s) error: %v", name, err)
+		}
+		return location
+	}
+
+	location := archiveFile("2026-10-01.log", "first\n", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	archiveFile("2026-10-02.log", "second\n", time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC))
+	archiveFile("2026-10-01.log", "first, again\n", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	archiveFile("2026-11-01.log", "november\n", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC))
+
+	if want := filepath.Join(archive, "2026-10.tar.gz") + "#2026-10-01.log"; location != want {
+		t.Errorf("location = %s, want %s", location, want)
+	}
+	october := tarContents(t, filepath.Join(archive, "2026-10.tar.gz"))
+	if len(october) != 2 || october["2026-10-01.log"] != "first, again\n" || october["2026-10-02.log"] != "second\n" {
+		t.Errorf("2026-10.tar.gz = %v", october)
+	}
+	if november := tarContents(t, filepath.Join(archive, "2026-11.tar.gz")); november["2026-11-01.log"] != "november\n" {
+		t.Errorf("2026-11.tar.gz = %v", november)
+	}
+
+	// A cancelled attempt leaves the archive of the month as it was
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	path := filepath.Join(logs, "2026-10-03.log")
+	writeFile(t, path, "third\n")
+	if _, err := a.Archive(ctx, ArchiveFile{Path: path, Name: "2026-10-03.log", Time: time.Date(2026, 10, 3, 0, 0, 0, 0, time.UTC)}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Archive() error = %v, want context.Canceled", err)
+	}
+	if october := tarContents(t, filepath.Join(archive, "2026-10.tar.gz")); len(october) != 2 {
+		t.Errorf("2026-10.tar.gz after a cancelled attempt = %v", october)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(archive, ".archive-*")); len(tmp) != 0 {
+		t.Errorf("temporary files left: %v", tmp)
+	}
+}
+
+// flakyArchiver fails the first failures calls and records the archived files.
+type flakyArchiver struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	names    []string
+}
+
+func (a *flakyArchiver) Archive(_ context.Context, f ArchiveFile) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls++
+	if a.calls <= a.failures {
+		return "", errors.New("store unavailable")
+	}
+	a.names = append(a.names, f.Name)
+	return "test://" + f.Name, nil
+}
+
+func TestRetention_ArchivesBeforeRemoving(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeLogFiles(t, dir, 3)
+	a := &flakyArchiver{failures: 1}
+	SetArchiver(a)
+	defer SetArchiver(nil)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxFiles = 0, 2
+	h := &FileHandler{basePath: dir, conf: conf}
+	h.cleanOldLogs()
+
+	// Both past files are archived, the first after a retry, the oldest is removed
+	sort.Strings(a.names)
+	if a.calls != 3 || len(a.names) != 2 || a.names[0] != filepath.Base(paths[0]) {
+		t.Errorf("calls = %d, archived = %v", a.calls, a.names)
+	}
+	if exists(paths[0]) || !exists(paths[1]) || !exists(paths[2]) {
+		t.Error("only the oldest file should be removed")
+	}
+
+	manifest := readManifest(dir)
+	e, ok := manifest[filepath.Base(paths[1])]
+	if len(manifest) != 2 || !ok || e.Location != "test://"+filepath.Base(paths[1]) || e.Size != 100 || len(e.SHA256) != 64 {
+		t.Errorf("manifest = %+v", manifest)
+	}
+
+	// Archived files are not archived again
+	h.cleanOldLogs()
+	if a.calls != 3 {
+		t.Errorf("calls = %d after the second cleanup, want 3", a.calls)
+	}
+}
+
+func TestRetention_DirArchiverManifest(t *testing.T) {
+	dir, archive := t.TempDir(), t.TempDir()
+	paths := writeLogFiles(t, dir, 3)
+	SetArchiver(NewDirArchiver(archive))
+	defer SetArchiver(nil)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxFiles = 0, 1
+	h := &FileHandler{basePath: dir, conf: conf}
+	h.cleanOldLogs()
+
+	// Every past file is a member of the archive of its month
+	manifest := readManifest(dir)
+	for _, p := range paths[:2] {
+		name := filepath.Base(p)
+		e, ok := manifest[name]
+		month := strings.TrimSuffix(name, ".log")[:7]
+		if !ok || e.Location != filepath.Join(archive, month+".tar.gz")+"#"+name {
+			t.Errorf("manifest entry for %s = %+v", name, e)
+			continue
+		}
+		if _, ok := tarContents(t, filepath.Join(archive, month+".tar.gz"))[name]; !ok {
+			t.Errorf("%s is missing from %s.tar.gz", name, month)
+		}
+	}
+}
+
+func TestRetention_KeepsFilesThatFailToArchive(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeLogFiles(t, dir, 2)
+	a := &flakyArchiver{failures: archiveAttempts}
+	SetArchiver(a)
+	defer SetArchiver(nil)
+	out, _ := setupDiagnostics(t)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxFiles = 0, 1
+	h := &FileHandler{basePath: dir, conf: conf}
+	h.cleanOldLogs()
+
+	if !exists(paths[0]) {
+		t.Error("a file that could not be archived should not be removed")
+	}
+	if a.calls != archiveAttempts || !strings.Contains(out.String(), "op=archive") {
+		t.Errorf("calls = %d, diagnostics = %q", a.calls, out.String())
+	}
+}
+
+// blockingArchiver blocks until the context of the attempt is done.
+type blockingArchiver struct {
+	started chan struct{}
+	err     chan error
+}
+
+func (a *blockingArchiver) Archive(ctx context.Context, _ ArchiveFile) (string, error) {
+	close(a.started)
+	<-ctx.Done()
+	a.err <- ctx.Err()
+	return "", ctx.Err()
+}
+
+func TestFileHandler_CloseStopsArchiving(t *testing.T) {
+	dir := t.TempDir()
+	writeLogFiles(t, dir, 2)
+	a := &blockingArchiver{started: make(chan struct{}), err: make(chan error, 1)}
+	SetArchiver(a)
+	defer SetArchiver(nil)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention = 0
+	h := &FileHandler{basePath: dir, conf: conf}
+	h.mu.Lock()
+	h.startCleanup()
+	h.mu.Unlock()
+	<-a.started
+
+	closed := make(chan struct{})
+	go func() {
+		_ = h.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close should cancel the archiver and return")
+	}
+	if err := <-a.err; !errors.Is(err, context.Canceled) {
+		t.Errorf("archiver context error = %v, want context.Canceled", err)
+	}
+}
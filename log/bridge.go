@@ -0,0 +1,135 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	stdlog "log"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedirectStdLog sends the output of the standard library log package to this
+// logger at the given level. The caller of log.Print and friends is reported
+// as the record's caller, and the standard logger's own prefix and flags are
+// cleared since timestamps are added by the handlers.
+func RedirectStdLog(level slog.Level) {
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(&stdLogWriter{level: level})
+}
+
+// stdLogWriter receives one complete message per Write from the standard logger.
+type stdLogWriter struct {
+	level slog.Level
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	ctx := context.Background()
+	if !logger.Enabled(ctx, w.level) {
+		return len(p), nil
+	}
+	var pcs [1]uintptr
+	runtime.Callers(4, pcs[:]) // skip: runtime.Callers, Write, log.(*Logger).output, log.Print
+	msg := strings.TrimSuffix(string(p), "\n")
+	r := slog.NewRecord(time.Now(), w.level, msg, pcs[0])
+	return len(p), logger.Handler().Handle(ctx, r)
+}
+
+// SetAsSlogDefault installs this logger as slog.Default, so that code using
+// slog.Info and friends writes through the configured handlers. As a side
+// effect of slog.SetDefault, the standard library log package then writes at
+// Info level as well; call RedirectStdLog afterwards to use another level.
+func SetAsSlogDefault() {
+	slog.SetDefault(slog.New(globalHandler{}))
+}
+
+// globalHandler forwards to the handler of the current package logger, so
+// that slog.Default keeps working after the handler chain is rebuilt.
+type globalHandler struct{}
+
+func (globalHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return logger.Handler().Enabled(ctx, level)
+}
+
+func (globalHandler) Handle(ctx context.Context, r slog.Record) error {
+	return logger.Handler().Handle(ctx, r)
+}
+
+func (h globalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
+}
+
+func (h globalHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
+}
+
+// Writer returns a writer that logs every line written to it as a separate
+// record at the given level, e.g. for the stdout and stderr of a subprocess.
+// An incomplete last line is buffered until the next write; Close logs it.
+func Writer(level slog.Level) io.WriteCloser {
+	return &lineWriter{level: level}
+}
+
+// lineWriter splits its input into lines and logs each of them.
+type lineWriter struct {
+	level slog.Level
+	buf   []byte
+	mu    sync.Mutex
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	// Keep the partial line in a fresh slice so the buffer does not grow forever
+	w.buf = append([]byte(nil), w.buf...)
+	return len(p), nil
+}
+
+// Close logs a remaining incomplete line.
+func (w *lineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.log(w.buf)
+		w.buf = nil
+	}
+	return nil
+}
+
+func (w *lineWriter) log(line []byte) {
+	ctx := context.Background()
+	if !logger.Enabled(ctx, w.level) {
+		return
+	}
+	msg := strings.TrimSuffix(string(line), "\r")
+	r := slog.NewRecord(time.Now(), w.level, msg, 0)
+	_ = logger.Handler().Handle(ctx, r)
+}
+
+// VerbosityLevel maps a logr or glog style verbosity to a level: 0 is Info,
+// 1 is Debug and 2 or more is Trace. Negative values are treated as 0.
+func VerbosityLevel(v int) slog.Level {
+	switch {
+	case v <= 0:
+		return slog.LevelInfo
+	case v == 1:
+		return slog.LevelDebug
+	default:
+		return LevelTrace
+	}
+}
@@ -0,0 +1,73 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	stdlog "log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRedirectStdLog_CallerPointsHere(t *testing.T) {
+	var buf bytes.Buffer
+	cleanup := setupTestLogger(&buf)
+	defer cleanup()
+	defer stdlog.SetOutput(os.Stderr)
+	defer stdlog.SetFlags(stdlog.Flags())
+
+	RedirectStdLog(slog.LevelWarn)
+	stdlog.Printf("legacy %s", "message")
+
+	out := buf.String()
+	if !strings.Contains(out, "WARN") || !strings.Contains(out, "legacy message") {
+		t.Errorf("unexpected output %q", out)
+	}
+	if caller := extractCaller(out); !strings.HasPrefix(caller, "bridge_test.go:") {
+		t.Errorf("expected caller bridge_test.go:*, got %s", caller)
+	}
+}
+
+func TestSetAsSlogDefault(t *testing.T) {
+	var buf bytes.Buffer
+	cleanup := setupTestLogger(&buf)
+	defer cleanup()
+	orig := slog.Default()
+	defer slog.SetDefault(orig)
+
+	SetAsSlogDefault()
+	slog.With("component", "db").Info("connected")
+
+	out := buf.String()
+	if !strings.Contains(out, "connected") || !strings.Contains(out, "component=db") {
+		t.Errorf("unexpected output %q", out)
+	}
+	if caller := extractCaller(out); !strings.HasPrefix(caller, "bridge_test.go:") {
+		t.Errorf("expected caller bridge_test.go:*, got %s", caller)
+	}
+}
+
+func TestWriter_SplitsLines(t *testing.T) {
+	var buf bytes.Buffer
+	cleanup := setupTestLogger(&buf)
+	defer cleanup()
+
+	w := Writer(slog.LevelInfo)
+	_, _ = fmt.Fprint(w, "first line\nsecond ")
+	_, _ = fmt.Fprint(w, "line\r\npartial")
+	if strings.Contains(buf.String(), "partial") {
+		t.Error("incomplete line should be buffered")
+	}
+	_ = w.Close()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 records, got %q", buf.String())
+	}
+	for i, want := range []string{"first line", "second line", "partial"} {
+		if !strings.HasSuffix(strings.TrimRight(lines[i], " "), want) {
+			t.Errorf("line %d = %q, want message %q", i, lines[i], want)
+		}
+	}
+}
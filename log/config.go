@@ -1,141 +1,516 @@
 package log
 
 import (
-	"bufio"
+	"cmp"
+	"context"
+	"crypto/cipher"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logcrypt"
 )
 
 // LevelTrace is a custom log level below Debug for trace messages.
 const LevelTrace = slog.Level(-8)
 
 var logger *slog.Logger
-var location = time.Local
 var config Config
 var logLevel = new(slog.LevelVar)
+var fileHandler *FileHandler    // the first file sink
+var fileHandlers []*FileHandler // all file sinks, one per file of LOG_FILES
+var forwarder *ForwardHandler
+var forwardListener *forwardServer
+var ringBuffer *RingBufferHandler
+var keyProvider logcrypt.KeyProvider
+var root = new(swapHandler)
+
+// configMu guards config against a reload while records are being formatted.
+var configMu sync.RWMutex
+
+var initOnce sync.Once
+var initErr error
 
 // Config holds the logging configuration.
 type Config struct {
-	save          string
-	level         string
+	prefix        string
+	save          bool
+	level         slog.Level
 	timezone      string
+	location      *time.Location
 	directory     string
-	retentionDays int
+	appName       string
+	fileName      string
+	files         *filename.Template
+	filesSpec     string
+	fileSinks     []fileSink
+	fileMode      os.FileMode
+	dirMode       os.FileMode
+	currentLink   string
+	processMode   string
+	socket        string
+	format        string
+	messagePolicy string
+	rotation      time.Duration
+	retention     time.Duration
+	maxTotalSize  int64
+	maxFiles      int
 	showCaller    bool
+	ringSize      int
+	ringFlush     bool
+	slowThreshold time.Duration
+	fileFallback  bool
+	strict        bool
+	errs          []error
 }
 
-// loadEnv loads environment variables from .env file if it exists.
-// This function parses simple KEY=VALUE pairs and ignores comments.
-func loadEnv(filename string) error {
-	file, err := os.Open(filename)
-	if err != nil {
-		// .env file is optional, so we don't return error if it doesn't exist
-		if os.IsNotExist(err) {
-			return nil
+// loc returns the location of timestamps.
+func (c *Config) loc() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
+// fileTemplate returns the template of log file names.
+func (c *Config) fileTemplate() *filename.Template {
+	if c.files == nil {
+		return defaultTemplate
+	}
+	return c.files
+}
+
+func init() {
+	logger = slog.New(root)
+
+	// With LOG_LAZY_INIT=true nothing is read until Init is called
+	if lazy, _ := parseBool(os.Getenv("LOG_LAZY_INIT")); lazy {
+		root.set(lazyHandler{})
+		return
+	}
+	_ = Init()
+}
+
+// Init configures the package-level logger from the LOG_* variables and the
+// configuration files. Problems are also reported to stderr. Init runs at
+// program start unless LOG_LAZY_INIT=true is set in the process environment;
+// then it runs when called, or by Reload or SetKeyProvider, and records logged
+// before are written to stderr. Later calls return the result of the first one.
+func Init() error {
+	initOnce.Do(func() {
+		reloadMu.Lock()
+		defer reloadMu.Unlock()
+		initErr = setup()
+	})
+	return initErr
+}
+
+func setup() error {
+	baseEnv = environ()
+	fileErr := loadConfigFiles()
+	if fileErr != nil {
+		fprintf(os.Stderr, "Failed to load configuration file: %v\n", fileErr)
+	}
+	cfg := loadConfig("LOG_")
+	if err := cfg.Validate(); err != nil {
+		if cfg.strict {
+			fprintf(os.Stderr, "Invalid logging configuration:\n%v\n", err)
+			os.Exit(2)
 		}
-		return err
+		fprintf(os.Stderr, "%v\n", err)
 	}
-	defer func(file *os.File) {
-		_ = file.Close()
-	}(file)
+	applyConfig(cfg)
 
-	scanner := bufio.NewScanner(file)
+	// Encrypt log files when a key is configured
+	if keyProvider == nil && getenv(logcrypt.EnvKey) != "" {
+		keyProvider = logcrypt.KeyFunc(func() ([]byte, error) {
+			return logcrypt.ParseKey(getenv(logcrypt.EnvKey))
+		})
+	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	setupErr := setupLogger()
+	if setupErr != nil {
+		fprintf(os.Stderr, "Failed to set up file logging: %v. Logging to console only.\n", setupErr)
+	}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	if b, _ := parseBool(getenv("LOG_STARTUP_REPORT")); b {
+		reportConfig(cfg)
+	}
+	if b, _ := parseBool(getenv("LOG_WATCH")); b {
+		go WatchConfig(context.Background(), 0)
+	}
+	return errors.Join(fileErr, setupErr)
+}
+
+// applyConfig makes cfg the current configuration. Handlers read the
+// configuration under configMu, so a record is formatted either with the old
+// or with the new configuration.
+func applyConfig(cfg Config) {
+	configMu.Lock()
+	config = cfg
+	configMu.Unlock()
+	setLogLevel()
+}
+
+// loadConfig reads the configuration from the variables named prefix+"LEVEL"
+// etc. in the environment and the configuration files. Invalid values are
+// replaced by their defaults and recorded as ConfigErrors, reported by Validate.
+func loadConfig(prefix string) Config {
+	return loadConfigWith(prefix, getenv)
+}
+
+// loadConfigWith is loadConfig reading the variables with getenv.
+func loadConfigWith(prefix string, getenv func(string) string) Config {
+	cfg := Config{prefix: prefix, location: time.Local}
+	env := func(name string) string {
+		return getenv(prefix + name)
+	}
+	invalid := func(name, value string, err error, def any) {
+		cfg.errs = append(cfg.errs, &ConfigError{Variable: prefix + name, Value: value, Err: err, Default: fmt.Sprint(def)})
+	}
+	boolVar := func(name string, def bool) bool {
+		value := env(name)
+		b, err := parseBool(value)
+		if err != nil {
+			invalid(name, value, err, def)
+			return def
 		}
+		if value == "" {
+			return def
+		}
+		return b
+	}
 
-		// Parse KEY=VALUE
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue // Skip invalid lines
+	modeVar := func(name string, def os.FileMode) os.FileMode {
+		value := env(name)
+		if value == "" {
+			return def
 		}
+		mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+		if err != nil || mode > 0o777 {
+			invalid(name, value, errors.New("must be octal permissions such as 0640"), fmt.Sprintf("%#o", def))
+			return def
+		}
+		return os.FileMode(mode)
+	}
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+	cfg.save = boolVar("SAVE", false)
+	cfg.strict = boolVar("STRICT", false)
 
-		// Remove quotes if present
-		value = strings.Trim(value, `"'`)
+	// Level: trace (default, includes all levels), debug, info, warn or error
+	cfg.level = LevelTrace
+	if levelStr := env("LEVEL"); levelStr != "" {
+		if level, err := parseLevelValue(levelStr); err == nil {
+			cfg.level = level
+		} else {
+			invalid("LEVEL", levelStr, err, levelName(cfg.level))
+		}
+	}
+
+	cfg.timezone = strings.TrimSpace(env("TIMEZONE"))
+	cfg.directory = env("DIRECTORY")
+	if cfg.directory == "" {
+		cfg.directory = "data/logs" // Default value
+	}
 
-		// Set environment variable only if not already set
-		if os.Getenv(key) == "" {
-			_ = os.Setenv(key, value)
+	// Sharing the directory with other processes: shared, lock, pid, writer or forward
+	processMode, ok := parseProcessMode(env("PROCESS_MODE"))
+	if !ok {
+		invalid("PROCESS_MODE", env("PROCESS_MODE"), errors.New("must be shared, lock, pid, writer or forward"), processMode)
+	}
+	cfg.socket = env("SOCKET")
+	if (processMode == processWriter || processMode == processForward) && cfg.socket == "" {
+		invalid("PROCESS_MODE", processMode, errors.New("requires "+prefix+"SOCKET"), processShared)
+		processMode = processShared
+	}
+	cfg.processMode = processMode
+
+	// Log file names relative to the directory, e.g. {year}/{month}/{app}-{date}.log
+	cfg.appName = cmp.Or(env("APP_NAME"), defaultAppName())
+	if err := filename.CheckAppName(cfg.appName); err != nil {
+		invalid("APP_NAME", cfg.appName, err, "the executable name")
+		cfg.appName = defaultAppName()
+	}
+	if rotationStr := env("ROTATION"); rotationStr != "" {
+		if interval, err := filename.ParseRotation(rotationStr); err == nil {
+			cfg.rotation = interval
+		} else {
+			invalid("ROTATION", rotationStr, err, "daily")
+			cfg.rotation = day
+		}
+	}
+	defaultName := filename.DefaultFor(cfg.rotation)
+	cfg.fileName = cmp.Or(env("FILE_NAME"), defaultName)
+	if cfg.processMode == processPID {
+		defaultName, cfg.fileName = filename.WithPID(defaultName), filename.WithPID(cfg.fileName)
+	}
+	files, err := filename.Parse(cfg.fileName, cfg.appName)
+	if err == nil && cfg.rotation > 0 && cfg.rotation < files.Resolution() {
+		err = errors.New("does not change within the rotation interval")
+	}
+	if err != nil {
+		invalid("FILE_NAME", cfg.fileName, err, defaultName)
+		cfg.fileName = defaultName
+		files, _ = filename.Parse(defaultName, cfg.appName)
+	}
+	cfg.files = files
+	// Without LOG_ROTATION, a new file is started when the name changes
+	if cfg.rotation == 0 {
+		cfg.rotation = files.Resolution()
+	}
+	// Several files with their own level ranges, e.g. all:trace+,errors:warn+
+	if cfg.filesSpec = env("FILES"); cfg.filesSpec != "" {
+		sinks, err := parseFileSinks(cfg.filesSpec, cfg.fileName, cfg.appName, cfg.processMode == processPID)
+		for _, sink := range sinks {
+			if err == nil && cfg.rotation < sink.files.Resolution() {
+				err = fmt.Errorf("%s: %q does not change within the rotation interval", sink.name, sink.fileName)
+			}
+		}
+		if err != nil {
+			invalid("FILES", cfg.filesSpec, err, "one file at "+prefix+"LEVEL")
+			cfg.filesSpec = ""
+		} else {
+			cfg.fileSinks = sinks
 		}
 	}
+	cfg.fileMode = modeVar("FILE_MODE", defaultFileMode)
+	cfg.dirMode = modeVar("DIR_MODE", defaultDirMode)
 
-	return scanner.Err()
-}
+	// Symlink in the directory pointing at the file being written
+	cfg.currentLink = env("CURRENT_LINK")
+	if cfg.currentLink != "" && !filepath.IsLocal(cfg.currentLink) {
+		invalid("CURRENT_LINK", cfg.currentLink, errors.New("must be a path inside the log directory"), "none")
+		cfg.currentLink = ""
+	}
 
-func init() {
-	// Try to load .env file from current directory
-	_ = loadEnv(".env")
-	config.save = os.Getenv("LOG_SAVE")
-	config.level = os.Getenv("LOG_LEVEL")
-	config.timezone = os.Getenv("LOG_TIMEZONE")
-	config.directory = os.Getenv("LOG_DIRECTORY")
-	if config.directory == "" {
-		config.directory = "data/logs" // Default value
+	// Output format: text (default), json or logfmt
+	format, ok := parseFormat(env("FORMAT"))
+	cfg.format = format
+	if !ok {
+		invalid("FORMAT", env("FORMAT"), errors.New("must be text, json or logfmt"), format)
+	}
+
+	// Handling of newlines and control characters in text messages
+	policy, ok := parseMessagePolicy(env("MESSAGE_POLICY"))
+	cfg.messagePolicy = policy
+	if !ok {
+		invalid("MESSAGE_POLICY", env("MESSAGE_POLICY"), errors.New("must be indent, escape, quote or raw"), policy)
 	}
 
 	// Show caller information (file:line)
-	config.showCaller = os.Getenv("LOG_SHOW_CALLER") == "true"
+	cfg.showCaller = boolVar("SHOW_CALLER", false)
 
-	// Parse retention days with default of 30 days
-	config.retentionDays = 30
-	if retentionStr := os.Getenv("LOG_RETENTION_DAYS"); retentionStr != "" {
-		if days, err := strconv.Atoi(retentionStr); err == nil && days > 0 {
-			config.retentionDays = days
+	// Retention as a duration, or in days with default of 30 days
+	cfg.retention = 30 * day
+	if retentionStr := env("RETENTION"); retentionStr != "" {
+		if d, err := parseDuration(retentionStr); err == nil && d >= 0 {
+			cfg.retention = d
+		} else {
+			invalid("RETENTION", retentionStr, errors.New("must be a duration such as 72h or 7d"), formatRetention(cfg.retention))
+		}
+	} else if retentionStr := env("RETENTION_DAYS"); retentionStr != "" {
+		if days, err := strconv.Atoi(strings.TrimSpace(retentionStr)); err == nil && days > 0 {
+			cfg.retention = time.Duration(days) * day
 		} else {
-			fprintf(os.Stderr, "Invalid LOG_RETENTION_DAYS value: %s. Using default: %d days\n", retentionStr, config.retentionDays)
+			invalid("RETENTION_DAYS", retentionStr, errors.New("must be a positive number of days"), 30)
 		}
 	}
 
-	// Set log level
-	setLogLevel()
+	// Limits on the total size and number of log files, oldest removed first
+	if sizeStr := env("MAX_TOTAL_SIZE"); sizeStr != "" {
+		if size, err := parseSize(sizeStr); err == nil {
+			cfg.maxTotalSize = size
+		} else {
+			invalid("MAX_TOTAL_SIZE", sizeStr, errors.New("must be a size such as 500MB or 2GiB"), "no limit")
+		}
+	}
+	if filesStr := env("MAX_FILES"); filesStr != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(filesStr)); err == nil && n >= 0 {
+			cfg.maxFiles = n
+		} else {
+			invalid("MAX_FILES", filesStr, errors.New("must be a number of files"), "no limit")
+		}
+	}
+
+	// Keep the most recent records in memory regardless of the level
+	if ringStr := env("RING_BUFFER"); ringStr != "" {
+		if size, err := strconv.Atoi(strings.TrimSpace(ringStr)); err == nil && size >= 0 {
+			cfg.ringSize = size
+		} else {
+			invalid("RING_BUFFER", ringStr, errors.New("must be a number of records"), 0)
+		}
+	}
+	cfg.ringFlush = boolVar("RING_FLUSH_ON_ERROR", false)
+
+	// Write records to stderr while the log file cannot be written
+	cfg.fileFallback = boolVar("FILE_FALLBACK", true)
+
+	// Timed operations taking longer than this are logged at Warn level
+	if slowStr := env("SLOW_THRESHOLD"); slowStr != "" {
+		if d, err := parseDuration(slowStr); err == nil && d >= 0 {
+			cfg.slowThreshold = d
+		} else {
+			invalid("SLOW_THRESHOLD", slowStr, errors.New("must be a duration such as 500ms"), "none")
+		}
+	}
 
 	// Set timezone for log timestamps
-	if config.timezone != "" {
-		loc, err := time.LoadLocation(config.timezone)
+	if cfg.timezone != "" {
+		l, err := time.LoadLocation(cfg.timezone)
 		if err != nil {
-			fprintf(os.Stderr, "Invalid LOG_TIMEZONE: %s. Falling back to local time.\n", err)
+			invalid("TIMEZONE", cfg.timezone, err, "local time")
 		} else {
-			location = loc
+			cfg.location = l
 		}
 	}
 
-	// Create handlers
+	return cfg
+}
+
+// setupLogger builds the handler chain from the current configuration and
+// installs it in the root handler. When the file sink cannot be created the
+// console handler is still installed and the error is returned. The previous
+// file handler passes records that are still in flight on to the new one.
+func setupLogger() error {
 	consoleHandler := newConsoleHandler(os.Stdout)
+	handlers := []slog.Handler{consoleHandler}
 
-	if config.save == "true" {
-		fileHandler := newFileHandler(config.directory)
-		multiHandler := newMultiHandler(consoleHandler, fileHandler)
-		logger = slog.New(multiHandler)
-	} else {
-		logger = slog.New(consoleHandler)
+	// Keep the ring buffer's history when the logger is rebuilt
+	ring := ringBuffer
+	if config.ringSize == 0 {
+		ring = nil
+	} else if ring == nil || len(ring.records) != config.ringSize {
+		ring = newRingBufferHandler(config.ringSize)
 	}
+	// DumpRecent and RecentHandler read the ring buffer under configMu
+	configMu.Lock()
+	ringBuffer = ring
+	configMu.Unlock()
+	if ringBuffer != nil {
+		ringBuffer.setFlushTo(nil)
+		// The ring buffer goes before the file sink so that flushed records
+		// precede the error that triggered the flush
+		handlers = append(handlers, ringBuffer)
+	}
+
+	oldFiles, oldForwarder := fileHandlers, forwarder
+	fileHandler, fileHandlers = nil, nil
+	err := setupFileHandler()
+	for _, h := range fileHandlers {
+		handlers = append(handlers, h)
+	}
+	if forwarder != nil {
+		handlers = append(handlers, forwarder)
+	}
+	root.set(newMultiHandler(handlers...))
+	for _, old := range oldFiles {
+		old.retire(successor(old))
+	}
+	if oldForwarder != nil && oldForwarder != forwarder {
+		_ = oldForwarder.Close()
+	}
+	return errors.Join(err, setupForwardListener())
 }
 
-// setLogLevel sets the logging level based on the LOG_LEVEL environment variable.
-func setLogLevel() {
-	switch config.level {
-	case "trace":
-		logLevel.Set(LevelTrace)
-	case "debug":
-		logLevel.Set(slog.LevelDebug)
-	case "info":
-		logLevel.Set(slog.LevelInfo)
-	case "warn":
-		logLevel.Set(slog.LevelWarn)
-	case "error":
-		logLevel.Set(slog.LevelError)
-	default:
-		logLevel.Set(LevelTrace) // Default to trace (includes all levels)
+// successor returns the file sink replacing old after a reload: the one with
+// the same name in LOG_FILES, or nil if there is none.
+func successor(old *FileHandler) *FileHandler {
+	for _, h := range fileHandlers {
+		if h.name == old.name {
+			return h
+		}
+	}
+	return nil
+}
+
+// setupForwardListener receives the records of other processes on LOG_SOCKET
+// in LOG_PROCESS_MODE=writer.
+func setupForwardListener() error {
+	if forwardListener != nil && (config.processMode != processWriter || forwardListener.socket != config.socket) {
+		_ = forwardListener.Close()
+		forwardListener = nil
+	}
+	if config.processMode != processWriter {
+		return nil
+	}
+	if forwardListener == nil {
+		s, err := listenForward(config.socket)
+		if err != nil {
+			return fmt.Errorf("listen on %s: %w", config.socket, err)
+		}
+		forwardListener = s
 	}
+	targets := fileHandlers
+	forwardListener.target.Store(&targets)
+	return nil
+}
+
+// setupFileHandler creates the file sink if LOG_SAVE is enabled. In
+// LOG_PROCESS_MODE=forward the sink sends the records to LOG_SOCKET instead;
+// the forwarder is kept while the socket stays the same.
+func setupFileHandler() error {
+	forwardTo := ""
+	if config.save && config.processMode == processForward {
+		forwardTo = config.socket
+	}
+	if forwarder != nil && forwarder.socket != forwardTo {
+		forwarder = nil // closed by setupLogger
+	}
+	if !config.save {
+		return nil
+	}
+	if forwardTo != "" {
+		if forwarder == nil {
+			forwarder = newForwardHandler(&config, logLevel, forwardTo)
+		}
+		if ringBuffer != nil && config.ringFlush {
+			ringBuffer.setFlushTo(forwarder)
+		}
+		return nil
+	}
+
+	var aead cipher.AEAD
+	if keyProvider != nil {
+		key, err := keyProvider.Key()
+		if err != nil {
+			return err
+		}
+		if aead, err = logcrypt.NewAEAD(key); err != nil {
+			return err
+		}
+	}
+
+	fileHandlers = newFileSinkHandlers(&config, logLevel, config.directory, aead)
+	fileHandler = fileHandlers[0]
+	if ringBuffer != nil && config.ringFlush {
+		ringBuffer.setFlushTo(fileHandler)
+	}
+	return nil
+}
+
+// SetKeyProvider enables encryption of log files with the key returned by p
+// and reopens the file sink. Passing nil disables encryption. If the key
+// cannot be obtained, file logging is disabled rather than falling back to
+// plaintext.
+func SetKeyProvider(p logcrypt.KeyProvider) error {
+	reloadMu.Lock()
+	keyProvider = p
+	if initPending() {
+		reloadMu.Unlock()
+		return Init()
+	}
+	defer reloadMu.Unlock()
+	return setupLogger()
+}
+
+// setLogLevel sets the logging level from LOG_LEVEL.
+func setLogLevel() {
+	logLevel.Set(config.level)
 }
@@ -0,0 +1,24 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	cleanup := setupTestLogger(&buf)
+	defer cleanup()
+
+	if FromContext(context.Background()) != logger {
+		t.Error("FromContext without a logger should return the package logger")
+	}
+
+	ctx := NewContext(context.Background(), logger.With("request_id", "abc"))
+	FromContext(ctx).Info("handled")
+	if !strings.Contains(buf.String(), "request_id=abc") {
+		t.Errorf("expected request-scoped attribute, got %q", buf.String())
+	}
+}
@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delays between attempts to reopen a broken log file. The delay doubles
+// after every failed attempt.
+const (
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = time.Minute
+)
+
+var errorHook func(sink string, err error)
+
+// OnError registers fn to be called whenever a sink fails, for example when
+// the log directory cannot be created or a write to the log file fails. fn is
+// called synchronously from the logging goroutine and must not log through
+// this package. nil removes the hook.
+func OnError(fn func(sink string, err error)) {
+	diag.mu.Lock()
+	defer diag.mu.Unlock()
+	errorHook = fn
+}
+
+var diag = &diagnostics{w: os.Stderr, seen: make(map[string]string)}
+
+// diagnostics writes internal errors of the handlers to stderr, each distinct
+// failure once until the sink recovers.
+type diagnostics struct {
+	w io.Writer
+	// seen holds the last error written for a sink and operation. It is not
+	// keyed by the error, whose text often contains a file name or an address,
+	// so that it stays as small as the set of sinks.
+	seen map[string]string
+	mu   sync.Mutex
+}
+
+// report passes err to the OnError hook and writes it to stderr unless the
+// same failure was already written since the sink last recovered.
+func (d *diagnostics) report(sink, op string, err error) {
+	d.mu.Lock()
+	hook := errorHook
+	key := sink + "\x00" + op
+	if msg := err.Error(); d.seen[key] != msg {
+		d.seen[key] = msg
+		d.write("sink error", sink, "op", op, "err", msg)
+	}
+	d.mu.Unlock()
+
+	if hook != nil {
+		hook(sink, err)
+	}
+}
+
+// recovered notes that sink works again, so that a later failure is written
+// to stderr even if it was seen before.
+func (d *diagnostics) recovered(sink string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	found := false
+	for key := range d.seen {
+		if strings.HasPrefix(key, sink+"\x00") {
+			delete(d.seen, key)
+			found = true
+		}
+	}
+	if found {
+		d.write("sink recovered", sink)
+	}
+}
+
+// write writes a logfmt line such as
+// `extended-log: msg="sink error" sink=file op=open err="..."`.
+func (d *diagnostics) write(msg, sink string, kv ...string) {
+	var buf bytes.Buffer
+	buf.WriteString("extended-log: ")
+	writeLogfmtPair(&buf, "msg", msg)
+	buf.WriteByte(' ')
+	writeLogfmtPair(&buf, "sink", sink)
+	for i := 0; i+1 < len(kv); i += 2 {
+		buf.WriteByte(' ')
+		writeLogfmtPair(&buf, kv[i], kv[i+1])
+	}
+	buf.WriteByte('\n')
+	_, _ = d.w.Write(buf.Bytes())
+}
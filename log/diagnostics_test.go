@@ -0,0 +1,114 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupDiagnostics captures internal diagnostics and sink errors.
+func setupDiagnostics(t *testing.T) (*bytes.Buffer, *[]string) {
+	t.Helper()
+	var out bytes.Buffer
+	var hooked []string
+	origW := diag.w
+	diag.w = &out
+	OnError(func(sink string, err error) {
+		hooked = append(hooked, sink+": "+err.Error())
+	})
+	t.Cleanup(func() {
+		diag.w = origW
+		diag.seen = make(map[string]string)
+		OnError(nil)
+	})
+	return &out, &hooked
+}
+
+func TestFileHandler_FallbackAndRecovery(t *testing.T) {
+	withFormat(t, formatText, false)
+	out, hooked := setupDiagnostics(t)
+
+	// A regular file where the log directory should be makes the file sink fail
+	dir := filepath.Join(t.TempDir(), "logs")
+	if err := os.WriteFile(dir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var fallback bytes.Buffer
+	h := newFileHandler(dir)
+	defer func() { _ = h.Close() }()
+	h.fallback = &fallback
+
+	ctx := context.Background()
+	for _, msg := range []string{"first", "second"} {
+		h.retryAt = time.Time{} // retry immediately
+		_ = h.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0))
+	}
+
+	if n := strings.Count(out.String(), `msg="sink error"`); n != 1 {
+		t.Errorf("repeated failure should be reported once, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "sink=file op=mkdir") {
+		t.Errorf("unexpected diagnostics %q", out.String())
+	}
+	if len(*hooked) != 3 { // constructor, first, second
+		t.Errorf("OnError should be called for every failure, got %v", *hooked)
+	}
+	if !strings.Contains(fallback.String(), "first") || !strings.Contains(fallback.String(), "second") {
+		t.Errorf("records should go to the fallback writer, got %q", fallback.String())
+	}
+
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	// Until the retry delay has passed records still go to the fallback writer
+	_ = h.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "third", 0))
+	if !strings.Contains(fallback.String(), "third") {
+		t.Error("record should go to the fallback writer before the retry delay has passed")
+	}
+	h.retryAt = time.Time{}
+	_ = h.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "fourth", 0))
+
+	data, err := os.ReadFile(filepath.Join(dir, time.Now().In(config.loc()).Format("2006-01-02")+".log"))
+	if err != nil {
+		t.Fatalf("log file should be reopened: %v", err)
+	}
+	if !strings.Contains(string(data), "fourth") {
+		t.Errorf("log file should contain the record, got %q", data)
+	}
+	if !strings.Contains(out.String(), `msg="sink recovered" sink=file`) {
+		t.Errorf("recovery should be reported, got:\n%s", out.String())
+	}
+}
+
+func TestFileHandler_RetryBackoff(t *testing.T) {
+	setupDiagnostics(t)
+	h := &FileHandler{}
+	for range 20 {
+		h.fail("open", os.ErrPermission)
+	}
+	if h.retryDelay != maxRetryDelay {
+		t.Errorf("retryDelay = %v, want %v", h.retryDelay, maxRetryDelay)
+	}
+}
+
+func TestDiagnostics_SeenIsBounded(t *testing.T) {
+	out, _ := setupDiagnostics(t)
+	for i := range 100 {
+		diag.report(SinkFile, "open", fmt.Errorf("open data/logs/%d.log: permission denied", i))
+	}
+	diag.report(SinkFile, "open", errors.New("open data/logs/99.log: permission denied"))
+
+	if len(diag.seen) != 1 {
+		t.Errorf("seen has %d entries, want one per sink and operation", len(diag.seen))
+	}
+	if n := strings.Count(out.String(), "sink error"); n != 100 {
+		t.Errorf("wrote %d errors, want each changed error once", n)
+	}
+}
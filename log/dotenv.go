@@ -0,0 +1,267 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadEnvFile reads the variables defined in a .env file. It does not modify
+// the environment. The file may contain:
+//
+//	# comments, also after unquoted values
+//	export KEY=value
+//	KEY='literal, may span lines'
+//	KEY="escapes \n \t \" \\ \$, may span lines"
+//	KEY=${OTHER}/logs $HOME ${UNSET:-default}
+//
+// Variables are expanded in unquoted and double-quoted values, using the
+// values defined earlier in the file and then the environment.
+func LoadEnvFile(filename string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseEnv(filename, string(data), os.LookupEnv)
+}
+
+// readEnvFile reads a .env file like LoadEnvFile, expanding variables with
+// lookup. A missing file yields no values and no error.
+func readEnvFile(filename string, lookup func(string) (string, bool)) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		// .env files are optional, so we don't return error if it doesn't exist
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return parseEnv(filename, string(data), lookup)
+}
+
+// envParser parses the contents of a .env file.
+type envParser struct {
+	name   string
+	src    string
+	pos    int
+	lookup func(string) (string, bool)
+	values map[string]string
+}
+
+func parseEnv(name, src string, lookup func(string) (string, bool)) (map[string]string, error) {
+	p := &envParser{
+		name:   name,
+		src:    strings.ReplaceAll(src, "\r\n", "\n"),
+		lookup: lookup,
+		values: make(map[string]string),
+	}
+	for {
+		p.skip(" \t\n")
+		if p.pos == len(p.src) {
+			return p.values, nil
+		}
+		if p.src[p.pos] == '#' {
+			p.skipLine()
+			continue
+		}
+		if err := p.parseLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *envParser) parseLine() error {
+	if strings.HasPrefix(p.src[p.pos:], "export") {
+		rest := p.src[p.pos+len("export"):]
+		if rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			p.pos += len("export")
+			p.skip(" \t")
+		}
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isEnvKeyChar(p.src[p.pos], p.pos == start) {
+		p.pos++
+	}
+	key := p.src[start:p.pos]
+	if key == "" {
+		return p.errorf("invalid variable name")
+	}
+
+	p.skip(" \t")
+	if p.pos == len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '#' {
+		// "export KEY" marks an existing variable for export in a shell
+		p.skipLine()
+		return nil
+	}
+	if p.src[p.pos] != '=' {
+		return p.errorf("expected \"=\" after %s", key)
+	}
+	p.pos++
+	p.skip(" \t")
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	p.values[key] = value
+	return nil
+}
+
+func (p *envParser) parseValue() (string, error) {
+	if p.pos == len(p.src) {
+		return "", nil
+	}
+	var b strings.Builder
+	switch quote := p.src[p.pos]; quote {
+	case '\'':
+		end := strings.IndexByte(p.src[p.pos+1:], '\'')
+		if end < 0 {
+			return "", p.errorf("unterminated single-quoted value")
+		}
+		b.WriteString(p.src[p.pos+1 : p.pos+1+end])
+		p.pos += end + 2
+	case '"':
+		start := p.pos
+		for p.pos++; ; {
+			if p.pos == len(p.src) {
+				p.pos = start
+				return "", p.errorf("unterminated double-quoted value")
+			}
+			c := p.src[p.pos]
+			if c == '"' {
+				p.pos++
+				break
+			}
+			switch {
+			case c == '\\' && p.pos+1 < len(p.src):
+				b.WriteString(unescapeEnv(p.src[p.pos+1]))
+				p.pos += 2
+			case c == '$':
+				if err := p.expand(&b); err != nil {
+					return "", err
+				}
+			default:
+				b.WriteByte(c)
+				p.pos++
+			}
+		}
+	default:
+		for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+			c := p.src[p.pos]
+			if c == '#' && p.pos > 0 && (p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t') {
+				break
+			}
+			switch {
+			case c == '\\' && strings.HasPrefix(p.src[p.pos:], `\$`):
+				b.WriteByte('$')
+				p.pos += 2
+			case c == '$':
+				if err := p.expand(&b); err != nil {
+					return "", err
+				}
+			default:
+				b.WriteByte(c)
+				p.pos++
+			}
+		}
+		p.skipLine()
+		return strings.TrimRight(b.String(), " \t"), nil
+	}
+
+	// Only a comment may follow a quoted value
+	p.skip(" \t")
+	if p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '#' {
+		return "", p.errorf("unexpected characters after quoted value")
+	}
+	p.skipLine()
+	return b.String(), nil
+}
+
+// expand writes the value of the variable reference at p.pos: $NAME, ${NAME}
+// or ${NAME:-default}. A "$" not followed by a name is written as is.
+func (p *envParser) expand(b *strings.Builder) error {
+	p.pos++ // $
+	if p.pos < len(p.src) && p.src[p.pos] == '{' {
+		end := strings.IndexAny(p.src[p.pos:], "}\n")
+		if end < 0 || p.src[p.pos+end] != '}' {
+			return p.errorf("unterminated variable reference")
+		}
+		ref := p.src[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		value, ok := p.get(name)
+		if hasDefault && (!ok || value == "") {
+			value = def
+		}
+		b.WriteString(value)
+		return nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isEnvKeyChar(p.src[p.pos], p.pos == start) && p.src[p.pos] != '.' && p.src[p.pos] != '-' {
+		p.pos++
+	}
+	if p.pos == start {
+		b.WriteByte('$')
+		return nil
+	}
+	value, _ := p.get(p.src[start:p.pos])
+	b.WriteString(value)
+	return nil
+}
+
+// get returns a variable defined earlier in the file or found by lookup.
+func (p *envParser) get(name string) (string, bool) {
+	if v, ok := p.values[name]; ok {
+		return v, true
+	}
+	return p.lookup(name)
+}
+
+func (p *envParser) skip(chars string) {
+	for p.pos < len(p.src) && strings.IndexByte(chars, p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *envParser) skipLine() {
+	if i := strings.IndexByte(p.src[p.pos:], '\n'); i >= 0 {
+		p.pos += i + 1
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+func (p *envParser) errorf(format string, args ...any) error {
+	line := strings.Count(p.src[:p.pos], "\n") + 1
+	return fmt.Errorf("%s:%d: %s", p.name, line, fmt.Sprintf(format, args...))
+}
+
+// isEnvKeyChar reports whether c may appear in a variable name.
+func isEnvKeyChar(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		return true
+	case c >= '0' && c <= '9', c == '.', c == '-':
+		return !first
+	}
+	return false
+}
+
+// unescapeEnv returns the character for the escape sequence "\c" in a
+// double-quoted value. Unknown sequences are kept.
+func unescapeEnv(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '"', '\\', '$':
+		return string(c)
+	}
+	return `\` + string(c)
+}
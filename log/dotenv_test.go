@@ -0,0 +1,84 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnvFile(t *testing.T) {
+	t.Setenv("DOTENV_TEST_HOME", "/home/app")
+	name := filepath.Join(t.TempDir(), ".env")
+	content := strings.Join([]string{
+		"# comment",
+		"export LOG_LEVEL=info",
+		"PLAIN = value with spaces   # comment",
+		"HASH=a#b",
+		"EMPTY=",
+		"SINGLE='no $DOTENV_TEST_HOME \\n here' # comment",
+		`DOUBLE="say \"hi\"\tthen\\n"`,
+		"MULTI=\"line one",
+		"line two\"",
+		"LITERAL='first",
+		"second'",
+		"DIR=${DOTENV_TEST_HOME}/logs",
+		"NESTED=$DIR/app-${LEVEL_UNSET:-default}",
+		`PRICE="\$5"`,
+		"CRLF=windows\r",
+		"export",
+	}, "\n")
+	writeFile(t, name, content)
+
+	values, err := LoadEnvFile(name)
+	if err != nil {
+		t.Fatalf("LoadEnvFile() error: %v", err)
+	}
+	want := map[string]string{
+		"LOG_LEVEL": "info",
+		"PLAIN":     "value with spaces",
+		"HASH":      "a#b",
+		"EMPTY":     "",
+		"SINGLE":    "no $DOTENV_TEST_HOME \\n here",
+		"DOUBLE":    "say \"hi\"\tthen\\n",
+		"MULTI":     "line one\nline two",
+		"LITERAL":   "first\nsecond",
+		"DIR":       "/home/app/logs",
+		"NESTED":    "/home/app/logs/app-default",
+		"PRICE":     "$5",
+		"CRLF":      "windows",
+	}
+	for k, v := range want {
+		if got, ok := values[k]; !ok || got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if len(values) != len(want) {
+		t.Errorf("got %d values, want %d: %v", len(values), len(want), values)
+	}
+	if _, ok := os.LookupEnv("PLAIN"); ok {
+		t.Error("LoadEnvFile should not modify the environment")
+	}
+}
+
+func TestLoadEnvFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"unterminated": "A=1\nB=\"open\n",
+		"name":         "A=1\n1B=2\n",
+		"separator":    "A=1\nB 2\n",
+		"trailing":     "A='x' y\n",
+		"reference":    "A=${B\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, content)
+		if _, err := LoadEnvFile(path); err == nil || !strings.Contains(err.Error(), path+":") {
+			t.Errorf("%s: error = %v, want an error with the position", name, err)
+		}
+	}
+
+	if _, err := LoadEnvFile(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("missing file: error = %v", err)
+	}
+}
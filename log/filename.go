@@ -0,0 +1,22 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+)
+
+// Default permissions of log files and directories, before the umask.
+const (
+	defaultFileMode os.FileMode = 0o666
+	defaultDirMode  os.FileMode = 0o777
+)
+
+var defaultTemplate, _ = filename.Parse(filename.Default, "")
+
+// defaultAppName is the name of the executable, used for {app}.
+func defaultAppName() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
@@ -0,0 +1,107 @@
+package log
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+)
+
+func TestLoadConfig_InvalidAppName(t *testing.T) {
+	t.Setenv("LOG_APP_NAME", "../../x")
+	t.Setenv("LOG_FILE_NAME", "{app}/{date}.log")
+	cfg := loadConfig("LOG_")
+	if cfg.appName != defaultAppName() || cfg.Validate() == nil {
+		t.Errorf("appName = %q, errors = %v", cfg.appName, cfg.Validate())
+	}
+	if name := cfg.files.Expand(time.Now()); !filepath.IsLocal(name) {
+		t.Errorf("file name %q escapes the log directory", name)
+	}
+}
+
+// fileConfig returns a copy of the configuration writing files named by template.
+func fileConfig(t *testing.T, template string) *Config {
+	t.Helper()
+	conf := config
+	files, err := filename.Parse(template, "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.fileName, conf.files = template, files
+	conf.location = time.Local
+	return &conf
+}
+
+func TestFileHandler_NestedLayoutAndCurrentLink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+	dir := t.TempDir()
+	conf := fileConfig(t, "{year}/{month}/{day}/{app}.log")
+	conf.currentLink = "current.log"
+	conf.fileMode, conf.dirMode = 0o640, 0o750
+
+	h := newConfiguredFileHandler(conf, logLevel, dir, nil)
+	defer func() { _ = h.Close() }()
+	slog.New(h).Info("nested")
+
+	name := filepath.Join(dir, time.Now().Format("2006/01/02"), "app.log")
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&^0o640 != 0 {
+		t.Errorf("file mode = %v, want at most 0640", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "current.log"))
+	if err != nil || !strings.Contains(string(data), "nested") {
+		t.Errorf("current.log = %q, %v", data, err)
+	}
+	if target, _ := os.Readlink(filepath.Join(dir, "current.log")); filepath.IsAbs(target) {
+		t.Errorf("link target %q should be relative", target)
+	}
+}
+
+func TestCleanOldLogs_NestedLayout(t *testing.T) {
+	dir := t.TempDir()
+	conf := fileConfig(t, "{year}/{month}/{app}-{date}.log")
+	conf.retention = 7 * day
+
+	old := time.Now().AddDate(0, 0, -40) // always in an earlier month
+	oldName := filepath.Join(dir, old.Format("2006/01"), "app-"+old.Format("2006-01-02")+".log")
+	freshName := filepath.Join(dir, time.Now().Format("2006/01"), "app-"+time.Now().Format("2006-01-02")+".log")
+	otherName := filepath.Join(dir, old.Format("2006/01"), "other-"+old.Format("2006-01-02")+".log")
+	for _, name := range []string{oldName, freshName} {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, name, "x")
+	}
+
+	h := &FileHandler{basePath: dir, conf: conf}
+	h.cleanOldLogs()
+	if _, err := os.Stat(oldName); !os.IsNotExist(err) {
+		t.Error("old log file should have been removed")
+	}
+	if _, err := os.Stat(filepath.Dir(oldName)); !os.IsNotExist(err) {
+		t.Error("emptied directory should have been removed")
+	}
+	if _, err := os.Stat(freshName); err != nil {
+		t.Error("fresh log file should still exist")
+	}
+
+	// Files of other applications are left alone
+	if err := os.MkdirAll(filepath.Dir(otherName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, otherName, "x")
+	h.cleanOldLogs()
+	if _, err := os.Stat(otherName); err != nil {
+		t.Error("files not matching the template should remain")
+	}
+}
@@ -0,0 +1,394 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Output formats supported by the console and file handlers (LOG_FORMAT).
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatLogfmt = "logfmt"
+)
+
+// Message policies for control characters in text output (LOG_MESSAGE_POLICY).
+const (
+	messageIndent = "indent" // continuation lines start with continuationMarker
+	messageEscape = "escape" // newlines and other control characters are escaped
+	messageQuote  = "quote"  // the message is written as a Go string literal
+	messageRaw    = "raw"    // the message is written verbatim
+)
+
+// continuationMarker starts every continuation line of a multi-line message
+// with the indent policy, so that no line can pass for a new record.
+const continuationMarker = "  > "
+
+// Timestamp layouts for the text and the structured formats.
+const (
+	textTimeLayout       = "02.01.2006 15:04:05.000"
+	structuredTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// levelText returns the name of a log level as written to the output.
+func levelText(level slog.Level) string {
+	switch {
+	case level < slog.LevelDebug:
+		return "TRACE"
+	case level == slog.LevelDebug:
+		return "DEBUG"
+	case level == slog.LevelWarn:
+		return "WARN"
+	case level == slog.LevelError:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+// parseLevel converts a level name such as "warn" or "ERROR" to a slog.Level.
+func parseLevel(s string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "trace":
+		return LevelTrace, true
+	case "debug":
+		return slog.LevelDebug, true
+	case "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return 0, false
+}
+
+// levelColor returns the ANSI color used for a log level on the console.
+func levelColor(level slog.Level) string {
+	switch {
+	case level < slog.LevelDebug:
+		return "\033[90m" // Gray
+	case level == slog.LevelDebug:
+		return "" // No color for debug
+	case level == slog.LevelWarn:
+		return "\033[33m" // Yellow
+	case level == slog.LevelError:
+		return "\033[31m" // Red
+	default:
+		return "\033[36m" // Cyan
+	}
+}
+
+// formatRecord renders a record in the output format of c, terminated by a
+// newline. Colors are only used by the text format.
+func formatRecord(c *Config, r slog.Record, color bool) []byte {
+	// Resolve attributes first, a LogValuer might log itself
+	attrs := recordAttrs(r)
+	var buf bytes.Buffer
+
+	configMu.RLock()
+	defer configMu.RUnlock()
+	switch c.format {
+	case formatJSON:
+		writeJSON(&buf, c, r, attrs)
+	case formatLogfmt:
+		writeLogfmt(&buf, c, r, attrs)
+	default:
+		writeText(&buf, c, r, attrs, color, c.showCaller)
+	}
+	return buf.Bytes()
+}
+
+// FormatText formats a record the way the console handler does in the text
+// format, without colors and without the trailing newline. The caller is
+// included when showCaller is set, regardless of LOG_SHOW_CALLER.
+func FormatText(r slog.Record, showCaller bool) string {
+	attrs := recordAttrs(r)
+	var buf bytes.Buffer
+
+	configMu.RLock()
+	defer configMu.RUnlock()
+	writeText(&buf, &config, r, attrs, false, showCaller)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// writeText writes "timestamp | LEVEL | [caller] message | key=value ...".
+func writeText(buf *bytes.Buffer, c *Config, r slog.Record, attrs []slog.Attr, color, showCaller bool) {
+	level := fmt.Sprintf("%-5s", levelText(r.Level))
+	if lc := levelColor(r.Level); color && lc != "" {
+		level = fmt.Sprintf("%s%s\x1b[0m", lc, level)
+	}
+
+	buf.WriteString(r.Time.In(c.loc()).Format(textTimeLayout))
+	buf.WriteString(" | ")
+	buf.WriteString(level)
+	buf.WriteString(" | ")
+	if showCaller {
+		buf.WriteString("[")
+		buf.WriteString(callerFromRecord(r))
+		buf.WriteString("] ")
+	}
+	buf.WriteString(sanitizeMessage(r.Message, c.messagePolicy))
+
+	if len(attrs) > 0 {
+		buf.WriteString(" |")
+		for _, a := range attrs {
+			buf.WriteByte(' ')
+			writeLogfmtPair(buf, a.Key, attrString(a.Value, c.loc()))
+		}
+	}
+	buf.WriteByte('\n')
+}
+
+// writeLogfmt writes "time=... level=... caller=... msg=... key=value ...".
+func writeLogfmt(buf *bytes.Buffer, c *Config, r slog.Record, attrs []slog.Attr) {
+	writeLogfmtPair(buf, "time", r.Time.In(c.loc()).Format(structuredTimeLayout))
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, "level", levelText(r.Level))
+	if c.showCaller {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, "caller", callerFromRecord(r))
+	}
+	buf.WriteByte(' ')
+	writeLogfmtPair(buf, "msg", r.Message)
+	for _, a := range attrs {
+		buf.WriteByte(' ')
+		writeLogfmtPair(buf, a.Key, attrString(a.Value, c.loc()))
+	}
+	buf.WriteByte('\n')
+}
+
+// writeJSON writes the record as a single-line JSON object.
+func writeJSON(buf *bytes.Buffer, c *Config, r slog.Record, attrs []slog.Attr) {
+	buf.WriteByte('{')
+	writeJSONPair(buf, "time", r.Time.In(c.loc()).Format(structuredTimeLayout))
+	buf.WriteByte(',')
+	writeJSONPair(buf, "level", levelText(r.Level))
+	if c.showCaller {
+		buf.WriteByte(',')
+		writeJSONPair(buf, "caller", callerFromRecord(r))
+	}
+	buf.WriteByte(',')
+	writeJSONPair(buf, "msg", r.Message)
+	for _, a := range attrs {
+		buf.WriteByte(',')
+		writeJSONPair(buf, a.Key, jsonValue(a.Value, c.loc()))
+	}
+	buf.WriteString("}\n")
+}
+
+func writeJSONPair(buf *bytes.Buffer, key string, value any) {
+	k, _ := json.Marshal(key)
+	v, err := json.Marshal(value)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(k)
+	buf.WriteByte(':')
+	buf.Write(v)
+}
+
+func writeLogfmtPair(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if needsQuoting(value) {
+		buf.WriteString(strconv.Quote(value))
+	} else {
+		buf.WriteString(value)
+	}
+}
+
+// needsQuoting reports whether a logfmt value must be quoted.
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, c := range s {
+		if c == '=' || c == '"' || c == '|' || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAttrs returns the record's attributes with groups flattened into
+// dotted keys, e.g. "http.status".
+func recordAttrs(r slog.Record) []slog.Attr {
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendAttr(attrs, "", a)
+		return true
+	})
+	return attrs
+}
+
+func appendAttr(attrs []slog.Attr, prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	a.Key = prefix + a.Key
+	return append(attrs, a)
+}
+
+// attrString formats an attribute value for the text and logfmt formats.
+func attrString(v slog.Value, loc *time.Location) string {
+	switch v.Kind() {
+	case slog.KindTime:
+		return v.Time().In(loc).Format(structuredTimeLayout)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+	}
+	return v.String()
+}
+
+// jsonValue converts an attribute value to a value suitable for json.Marshal.
+func jsonValue(v slog.Value, loc *time.Location) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+		return v.String()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().In(loc).Format(structuredTimeLayout)
+	default:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		if _, err := json.Marshal(v.Any()); err != nil {
+			return fmt.Sprint(v.Any())
+		}
+		return v.Any()
+	}
+}
+
+// sanitizeMessage applies a message policy. Only the text format needs it:
+// JSON and logfmt always encode control characters.
+func sanitizeMessage(msg, policy string) string {
+	switch policy {
+	case messageRaw:
+		return msg
+	case messageQuote:
+		return strconv.Quote(msg)
+	case messageEscape:
+		return escapeSeparator(escapeControl(msg, false))
+	default:
+		msg = escapeSeparator(escapeControl(msg, true))
+		return strings.ReplaceAll(msg, "\n", "\n"+continuationMarker)
+	}
+}
+
+// escapeSeparator escapes "| " at the start of a text message or after a
+// space as "\| ", so that what follows cannot be read back as attributes.
+func escapeSeparator(msg string) string {
+	if !strings.Contains(msg, "| ") {
+		return msg
+	}
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if strings.HasPrefix(msg[i:], "| ") && (i == 0 || msg[i-1] == ' ') {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
+
+// escapeControl replaces control characters with Go escape sequences. Tabs
+// are kept; newlines are kept when keepNewlines is set. Without keepNewlines
+// backslashes are escaped as well, so the result can be unescaped unambiguously.
+func escapeControl(s string, keepNewlines bool) string {
+	if !needsEscaping(s, keepNewlines) {
+		return s
+	}
+
+	var b strings.Builder
+	for i, c := range s {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == '\n' && keepNewlines, c == '\t':
+			b.WriteRune(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\\' && !keepNewlines:
+			b.WriteString(`\\`)
+		case c == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, s[i])
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		case unicode.IsControl(c) || unicode.Is(unicode.Bidi_Control, c):
+			fmt.Fprintf(&b, `\u%04x`, c)
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// needsEscaping reports whether escapeControl would change s.
+func needsEscaping(s string, keepNewlines bool) bool {
+	for _, c := range s {
+		if c == '\t' || (c == '\n' && keepNewlines) {
+			continue
+		}
+		if c < 0x20 || c == 0x7f || c == utf8.RuneError || (c == '\\' && !keepNewlines) ||
+			unicode.IsControl(c) || unicode.Is(unicode.Bidi_Control, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMessagePolicy validates a LOG_MESSAGE_POLICY value.
+func parseMessagePolicy(s string) (string, bool) {
+	switch p := strings.ToLower(strings.TrimSpace(s)); p {
+	case "", messageIndent:
+		return messageIndent, true
+	case messageEscape, messageQuote, messageRaw:
+		return p, true
+	}
+	return messageIndent, false
+}
+
+// parseFormat validates a LOG_FORMAT value.
+func parseFormat(s string) (string, bool) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case "", formatText:
+		return formatText, true
+	case formatJSON, formatLogfmt:
+		return f, true
+	}
+	return formatText, false
+}
@@ -0,0 +1,236 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// withFormat switches the output format for the duration of a test.
+func withFormat(t *testing.T, format string, showCaller bool) {
+	t.Helper()
+	origFormat, origShowCaller, origLocation := config.format, config.showCaller, config.location
+	config.format = format
+	config.showCaller = showCaller
+	config.location = time.UTC
+	t.Cleanup(func() {
+		config.format, config.showCaller, config.location = origFormat, origShowCaller, origLocation
+	})
+}
+
+func TestFormatRecord_TextWithoutAttrs(t *testing.T) {
+	withFormat(t, formatText, false)
+
+	r := slog.NewRecord(time.Date(2026, 4, 7, 12, 0, 0, 0, time.UTC), slog.LevelWarn, "plain", 0)
+	got := string(formatRecord(&config, r, false))
+	if want := "07.04.2026 12:00:00.000 | WARN  | plain\n"; got != want {
+		t.Errorf("formatRecord() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatRecord_TextAttrs(t *testing.T) {
+	withFormat(t, formatText, false)
+
+	r := slog.NewRecord(time.Date(2026, 4, 7, 12, 0, 0, 0, time.UTC), slog.LevelInfo, "done", 0)
+	r.AddAttrs(slog.Int("status", 200), slog.String("user", "John Doe"), slog.Group("db", slog.Bool("cached", true)))
+	got := string(formatRecord(&config, r, false))
+	want := `07.04.2026 12:00:00.000 | INFO  | done | status=200 user="John Doe" db.cached=true` + "\n"
+	if got != want {
+		t.Errorf("formatRecord() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatRecord_JSON(t *testing.T) {
+	withFormat(t, formatJSON, true)
+
+	r := testRecord(slog.LevelError, "failed")
+	r.AddAttrs(slog.Any("err", errors.New("boom")), slog.Duration("took", time.Second), slog.Int("n", 3))
+	out := formatRecord(&config, r, true)
+
+	var m map[string]any
+	if err := json.Unmarshal(out, &m); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, out)
+	}
+	if m["level"] != "ERROR" || m["msg"] != "failed" || m["err"] != "boom" || m["took"] != "1s" || m["n"] != float64(3) {
+		t.Errorf("unexpected JSON output: %s", out)
+	}
+	if !strings.HasPrefix(m["caller"].(string), "format_test.go:") {
+		t.Errorf("expected caller format_test.go, got %v", m["caller"])
+	}
+	if strings.Contains(string(out), "\x1b[") {
+		t.Errorf("JSON output must not contain colors: %s", out)
+	}
+}
+
+func TestFormatRecord_Logfmt(t *testing.T) {
+	withFormat(t, formatLogfmt, false)
+
+	r := slog.NewRecord(time.Date(2026, 4, 7, 12, 0, 0, 0, time.UTC), LevelTrace, "hello world", 0)
+	r.AddAttrs(slog.String("k", "v"))
+	got := string(formatRecord(&config, r, false))
+	want := `time=2026-04-07T12:00:00.000Z level=TRACE msg="hello world" k=v` + "\n"
+	if got != want {
+		t.Errorf("formatRecord() = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_WithAttrsAndGroup(t *testing.T) {
+	withFormat(t, formatText, false)
+	logLevel.Set(slog.LevelInfo)
+
+	var buf bytes.Buffer
+	l := slog.New(newConsoleHandler(&buf)).With("service", "api").WithGroup("req").With("id", 7)
+	l.Info("handled", "status", 200)
+
+	out := buf.String()
+	if !strings.Contains(out, "handled | service=api req.id=7 req.status=200") {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestFormatRecord_RoundTrip(t *testing.T) {
+	for _, format := range []string{formatText, formatJSON, formatLogfmt} {
+		t.Run(format, func(t *testing.T) {
+			withFormat(t, format, true)
+
+			r := testRecord(slog.LevelWarn, "disk | almost full")
+			r.AddAttrs(slog.String("path", "/var/log"), slog.Int("free", 5))
+			line := formatRecord(&config, r, false)
+
+			e, err := logparse.ParseLine(string(line), time.UTC)
+			if err != nil {
+				t.Fatalf("ParseLine(%q) error: %v", line, err)
+			}
+			if e.Level != slog.LevelWarn || e.Message != "disk | almost full" || !e.Time.Equal(r.Time) {
+				t.Errorf("unexpected entry %+v from %q", e, line)
+			}
+			if !strings.HasPrefix(e.Caller, "format_test.go:") {
+				t.Errorf("caller = %q", e.Caller)
+			}
+			if v, ok := e.Attr("path"); !ok || v.String() != "/var/log" {
+				t.Errorf("path attr = %v from %q", v, line)
+			}
+		})
+	}
+}
+
+func TestMultiHandler_WithAttrsToFile(t *testing.T) {
+	withFormat(t, formatText, false)
+	logLevel.Set(slog.LevelInfo)
+
+	var buf bytes.Buffer
+	dir := t.TempDir()
+	fh := newFileHandler(dir)
+	defer func() { _ = fh.Close() }()
+	h := newMultiHandler(newConsoleHandler(&buf), fh).WithAttrs([]slog.Attr{slog.String("k", "v")})
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "shared", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "shared | k=v") {
+		t.Errorf("console output missing attrs: %q", buf.String())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, time.Now().In(config.loc()).Format("2006-01-02")+".log"))
+	if err != nil {
+		t.Fatalf("could not read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "shared | k=v") {
+		t.Errorf("file output missing attrs: %q", data)
+	}
+}
+
+func TestSanitizeMessage(t *testing.T) {
+	msg := "line one\nline \x1b[31mtwo\r\t\\"
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{messageIndent, "line one\n  > line \\x1b[31mtwo\\r\t\\"},
+		{messageEscape, `line one\nline \x1b[31mtwo\r` + "\t" + `\\`},
+		{messageQuote, `"line one\nline \x1b[31mtwo\r\t\\"`},
+		{messageRaw, msg},
+	}
+
+	orig := config.messagePolicy
+	defer func() { config.messagePolicy = orig }()
+	for _, tt := range tests {
+		config.messagePolicy = tt.policy
+		if got := sanitizeMessage(msg, config.messagePolicy); got != tt.want {
+			t.Errorf("%s: sanitizeMessage() = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
+
+func TestMessagePolicy_Default(t *testing.T) {
+	t.Setenv("LOG_MESSAGE_POLICY", "")
+	cfg := loadConfig("LOG_")
+	if cfg.messagePolicy != messageIndent {
+		t.Fatalf("default policy = %q, want %q", cfg.messagePolicy, messageIndent)
+	}
+	cfg.location = time.UTC
+
+	r := slog.NewRecord(time.Date(2026, 4, 7, 12, 0, 0, 0, time.UTC), slog.LevelWarn, "failed | retrying\nagain", 0)
+	if got, want := string(formatRecord(&cfg, r, false)), "07.04.2026 12:00:00.000 | WARN  | failed \\| retrying\n  > again\n"; got != want {
+		t.Errorf("formatRecord() = %q, want %q", got, want)
+	}
+}
+
+func TestSanitizeMessage_PreventsInjection(t *testing.T) {
+	withFormat(t, formatText, false)
+	orig := config.messagePolicy
+	defer func() { config.messagePolicy = orig }()
+
+	forged := "login failed\n07.04.2026 12:00:01.000 | INFO  | login succeeded | user=admin"
+	for _, policy := range []string{messageIndent, messageEscape, messageQuote} {
+		config.messagePolicy = policy
+		r := slog.NewRecord(time.Date(2026, 4, 7, 12, 0, 0, 0, time.UTC), slog.LevelWarn, forged, 0)
+		out := string(formatRecord(&config, r, false))
+
+		var entries []logparse.Entry
+		for e, err := range logparse.Entries(strings.NewReader(out), time.UTC) {
+			if err != nil {
+				t.Fatalf("%s: parse error: %v", policy, err)
+			}
+			entries = append(entries, e)
+		}
+		if len(entries) != 1 || entries[0].Level != slog.LevelWarn {
+			t.Errorf("%s: message produced %d entries: %q", policy, len(entries), out)
+		}
+	}
+}
+
+func TestSanitizeMessage_PreventsAttrInjection(t *testing.T) {
+	withFormat(t, formatText, false)
+	orig := config.messagePolicy
+	defer func() { config.messagePolicy = orig }()
+
+	for _, msg := range []string{"login failed | user=admin", "| user=admin", "a | | user=admin"} {
+		for _, policy := range []string{messageIndent, messageEscape, messageQuote} {
+			config.messagePolicy = policy
+			r := slog.NewRecord(time.Date(2026, 4, 7, 12, 0, 0, 0, time.UTC), slog.LevelWarn, msg, 0)
+			r.AddAttrs(slog.String("ip", "10.0.0.1"))
+			line := string(formatRecord(&config, r, false))
+
+			e, err := logparse.ParseLine(line, time.UTC)
+			if err != nil {
+				t.Fatalf("%s: ParseLine(%q) error: %v", policy, line, err)
+			}
+			if _, ok := e.Attr("user"); ok || e.Message != msg || len(e.Attrs) != 1 {
+				t.Errorf("%s: message %q was read back as %+v from %q", policy, msg, e, line)
+			}
+			if got := e.String(); !strings.HasSuffix(got, " | ip=10.0.0.1") || strings.Count(got, "| ") != strings.Count(line, "| ") {
+				t.Errorf("%s: String() = %q", policy, got)
+			}
+		}
+	}
+}
@@ -1,16 +1,21 @@
 package log
 
 import (
+	"cmp"
 	"context"
+	"crypto/cipher"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logcrypt"
 )
 
 // callerFromRecord extracts the file and line number from a slog.Record's PC.
@@ -26,6 +31,7 @@ func callerFromRecord(r slog.Record) string {
 // ConsoleHandler is a custom slog handler that outputs colorful logs to the console.
 type ConsoleHandler struct {
 	w     io.Writer
+	conf  *Config
 	level slog.Leveler
 	mu    sync.Mutex
 }
@@ -33,6 +39,7 @@ type ConsoleHandler struct {
 func newConsoleHandler(w io.Writer) *ConsoleHandler {
 	return &ConsoleHandler{
 		w:     w,
+		conf:  &config,
 		level: logLevel,
 	}
 }
@@ -42,198 +49,459 @@ func (h *ConsoleHandler) Enabled(_ context.Context, level slog.Level) bool {
 }
 
 func (h *ConsoleHandler) Handle(_ context.Context, r slog.Record) error {
-	var levelColor string
-	var levelText string
-
-	// Set color and text for each log level
-	switch {
-	case r.Level < slog.LevelDebug:
-		levelText = "TRACE"
-		levelColor = "\033[90m" // Gray
-	case r.Level == slog.LevelDebug:
-		levelText = "DEBUG"
-		levelColor = "" // No color for debug
-	case r.Level == slog.LevelWarn:
-		levelText = "WARN"
-		levelColor = "\033[33m" // Yellow
-	case r.Level == slog.LevelError:
-		levelText = "ERROR"
-		levelColor = "\033[31m" // Red
-	default:
-		levelText = "INFO"
-		levelColor = "\033[36m" // Cyan
-	}
-
-	// Adjust level text length to 5 characters
-	levelText = fmt.Sprintf("%-5s", levelText)
-	if levelColor != "" {
-		levelText = fmt.Sprintf("%s%s\x1b[0m", levelColor, levelText)
-	}
-
-	timestamp := r.Time.In(location).Format("02.01.2006 15:04:05.000")
-
-	var message string
-	if config.showCaller {
-		caller := callerFromRecord(r)
-		message = fmt.Sprintf("%s | %s | [%s] %s\n", timestamp, levelText, caller, r.Message)
-	} else {
-		message = fmt.Sprintf("%s | %s | %s\n", timestamp, levelText, r.Message)
-	}
+	message := formatRecord(h.conf, r, true)
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	_, err := h.w.Write([]byte(message))
+	n, err := h.w.Write(message)
+	reportWrite(SinkConsole, r.Level, n, err)
+	if err != nil {
+		diag.report(SinkConsole, "write", err)
+	}
 	return err
 }
 
-func (h *ConsoleHandler) WithAttrs(_ []slog.Attr) slog.Handler {
-	return h
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
 }
 
-func (h *ConsoleHandler) WithGroup(_ string) slog.Handler {
-	return h
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
 }
 
-// FileHandler is a custom slog handler that writes logs to daily files without colors.
+// FileHandler is a custom slog handler that writes logs to files without colors.
+// A timer closes the file at the end of every rotation period.
+// When an AEAD cipher is set, every record is encrypted before it reaches the disk.
+// While the log file cannot be opened or written, records go to the fallback
+// writer and the file is reopened with increasing delays.
 type FileHandler struct {
-	basePath string
-	file     *os.File
-	level    slog.Leveler
-	mu       sync.Mutex
+	basePath   string
+	conf       *Config
+	file       *os.File
+	path       string
+	rotateAt   time.Time
+	timer      *time.Timer
+	rotated    bool
+	aead       cipher.AEAD
+	level      slog.Leveler
+	levels     *levelRange // the levels of a file of LOG_FILES or FileOptions
+	name       string      // the name in LOG_FILES
+	files      *filename.Template
+	retained   []*filename.Template // the templates of the files it removes
+	secondary  bool                 // another file of LOG_FILES removes the old files
+	noLink     bool                 // LOG_CURRENT_LINK points at another file
+	fallback   io.Writer
+	retired    bool
+	successor  *FileHandler
+	failed     bool
+	retryAt    time.Time
+	retryDelay time.Duration
+	cleanup    *retentionPolicy // the next cleanup to run in the background
+	cleanDone  chan struct{}    // closed when the cleanup goroutine exits
+	cleanStop  context.CancelFunc
+	mu         sync.Mutex
 }
 
 func newFileHandler(basePath string) *FileHandler {
+	return newEncryptedFileHandler(basePath, nil)
+}
+
+func newEncryptedFileHandler(basePath string, aead cipher.AEAD) *FileHandler {
+	return newConfiguredFileHandler(&config, logLevel, basePath, aead)
+}
+
+// newConfiguredFileHandler returns a file handler that uses the format,
+// timezone, retention and fallback settings of conf.
+func newConfiguredFileHandler(conf *Config, level slog.Leveler, basePath string, aead cipher.AEAD) *FileHandler {
 	h := &FileHandler{
 		basePath: basePath,
-		level:    logLevel,
+		conf:     conf,
+		aead:     aead,
+		level:    level,
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return h.open()
+}
+
+// open sets up the fallback writer and opens the log file. configMu must be
+// held for reading.
+func (h *FileHandler) open() *FileHandler {
+	if h.conf.fileFallback {
+		h.fallback = os.Stderr
 	}
 	h.ensureLogFile()
 	return h
 }
 
 func (h *FileHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if h.levels != nil {
+		return h.levels.contains(level)
+	}
 	return level >= h.level.Level()
 }
 
-func (h *FileHandler) Handle(_ context.Context, r slog.Record) error {
+// sink returns the name the handler reports metrics and diagnostics under:
+// SinkFile, followed by ":" and the name of its file for LOG_FILES.
+func (h *FileHandler) sink() string {
+	if h.name == "" {
+		return SinkFile
+	}
+	return SinkFile + ":" + h.name
+}
+
+// template returns the template of the file names.
+func (h *FileHandler) template() *filename.Template {
+	if h.files != nil {
+		return h.files
+	}
+	return h.conf.fileTemplate()
+}
+
+func (h *FileHandler) Handle(ctx context.Context, r slog.Record) error {
+	// Format outside h.mu, a LogValuer might log through this handler
+	message := formatRecord(h.conf, r, false)
+
 	h.mu.Lock()
+	if h.retired {
+		// Replaced by a reload while the record was on its way
+		successor := h.successor
+		h.mu.Unlock()
+		if successor != nil {
+			return successor.Handle(ctx, r)
+		}
+		metrics.Dropped(h.sink(), r.Level)
+		return nil
+	}
 	defer h.mu.Unlock()
 
+	configMu.RLock()
 	h.ensureLogFile()
+	lock := h.conf.processMode == processLock
+	configMu.RUnlock()
 
-	var levelText string
-	switch {
-	case r.Level < slog.LevelDebug:
-		levelText = "TRACE"
-	case r.Level == slog.LevelDebug:
-		levelText = "DEBUG"
-	case r.Level == slog.LevelWarn:
-		levelText = "WARN"
-	case r.Level == slog.LevelError:
-		levelText = "ERROR"
-	default:
-		levelText = "INFO"
-	}
-	levelText = fmt.Sprintf("%-5s", levelText)
-	timestamp := r.Time.In(location).Format("02.01.2006 15:04:05.000")
-
-	var message string
-	if config.showCaller {
-		caller := callerFromRecord(r)
-		message = fmt.Sprintf("%s | %s | [%s] %s\n", timestamp, levelText, caller, r.Message)
-	} else {
-		message = fmt.Sprintf("%s | %s | %s\n", timestamp, levelText, r.Message)
+	return h.writeMessage(r.Level, message, lock)
+}
+
+// writeForwarded writes a record formatted by another process, received in
+// LOG_PROCESS_MODE=writer.
+func (h *FileHandler) writeForwarded(level slog.Level, message []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.retired {
+		if h.successor != nil {
+			return h.successor.writeForwarded(level, message)
+		}
+		metrics.Dropped(h.sink(), level)
+		return nil
 	}
 
-	if h.file != nil {
-		_, err := h.file.Write([]byte(message))
+	configMu.RLock()
+	h.ensureLogFile()
+	lock := h.conf.processMode == processLock
+	configMu.RUnlock()
+
+	return h.writeMessage(level, message, lock)
+}
+
+// writeMessage writes a formatted record to the open log file, or to the
+// fallback writer if there is none. With lock, the file is locked while the
+// record is written. h.mu must be held.
+func (h *FileHandler) writeMessage(level slog.Level, message []byte, lock bool) error {
+	if h.file == nil {
+		h.writeFallback(level, message)
+		return nil
+	}
+
+	// Other processes writing to the same file wait for the record
+	if lock {
+		if err := lockFile(h.file); err != nil {
+			diag.report(h.sink(), "lock", err)
+		}
+		defer unlockFile(h.file)
+	}
+	var n int
+	var err error
+	if h.aead != nil {
+		n, err = logcrypt.NewWriter(h.file, h.aead).Write(message)
+	} else {
+		n, err = h.file.Write(message)
+	}
+	reportWrite(h.sink(), level, n, err)
+	if err != nil {
+		// Reopen the file later, it may have been removed or the disk may be full
+		h.fail("write", err)
+		_ = h.file.Close()
+		h.file = nil
+		h.writeFallback(level, message)
 		return err
 	}
+	if h.failed {
+		h.failed, h.retryDelay = false, 0
+		diag.recovered(h.sink())
+	}
 	return nil
 }
 
-func (h *FileHandler) WithAttrs(_ []slog.Attr) slog.Handler {
-	return h
+// writeFallback writes a record the file sink could not write to the fallback writer.
+func (h *FileHandler) writeFallback(level slog.Level, message []byte) {
+	metrics.Dropped(h.sink(), level)
+	if h.fallback != nil {
+		_, _ = h.fallback.Write(message)
+	}
 }
 
-func (h *FileHandler) WithGroup(_ string) slog.Handler {
-	return h
+// fail reports a failure of the file sink and delays the next attempt to
+// open the log file.
+func (h *FileHandler) fail(op string, err error) {
+	metrics.WriteError(h.sink(), err)
+	diag.report(h.sink(), op, err)
+
+	h.failed = true
+	h.retryDelay = min(max(2*h.retryDelay, minRetryDelay), maxRetryDelay)
+	h.retryAt = time.Now().Add(h.retryDelay)
+}
+
+func (h *FileHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
+}
+
+func (h *FileHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
+}
+
+// retire closes the log file and passes later records to successor, which
+// may be nil if file logging was disabled.
+func (h *FileHandler) retire(successor *FileHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopTimer()
+	if h.file != nil {
+		_ = h.file.Close()
+		h.file = nil
+	}
+	h.retired = true
+	h.successor = successor
+}
+
+// Close closes the currently open log file, stops archiving in a running
+// cleanup of old files and waits for the cleanup to finish.
+func (h *FileHandler) Close() error {
+	h.mu.Lock()
+	h.stopTimer()
+	var err error
+	if h.file != nil {
+		err = h.file.Close()
+		h.file = nil
+	}
+	done := h.cleanDone
+	if done != nil {
+		h.cleanStop()
+	}
+	h.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+	return err
+}
+
+// rotate is run by the timer at the end of the rotation period and switches
+// to the file of the next period.
+func (h *FileHandler) rotate() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.retired || h.file == nil {
+		return // closed, the next record opens the current file
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	if time.Now().Before(h.rotateAt) {
+		// The wall clock was set back since the timer was started
+		h.scheduleRotation()
+		return
+	}
+	h.ensureLogFile()
+}
+
+// scheduleRotation starts the timer that rotates the file at rotateAt.
+func (h *FileHandler) scheduleRotation() {
+	h.stopTimer()
+	h.timer = time.AfterFunc(time.Until(h.rotateAt), h.rotate)
+}
+
+func (h *FileHandler) stopTimer() {
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
 }
 
-// ensureLogFile ensures that the log file for the current day is open.
+// ensureLogFile ensures that the log file for the current rotation period is open.
 func (h *FileHandler) ensureLogFile() {
-	now := time.Now().In(location)
-	fileName := filepath.Join(h.basePath, now.Format("2006-01-02")+".log")
+	now := time.Now().In(h.conf.loc())
 
-	// Check if the file is already open and is current
+	// The file stays current until the end of its rotation period
+	if h.file != nil && now.Before(h.rotateAt) {
+		return
+	}
+	start := periodStart(now, h.conf.rotation)
+	fileName := filepath.Join(h.basePath, h.template().Expand(start))
+	if h.aead != nil {
+		fileName += logcrypt.Extension
+	}
+
+	rotating := h.rotated || h.file != nil
 	if h.file != nil {
-		stat, err := h.file.Stat()
-		if err == nil && stat.Name() == filepath.Base(fileName) {
+		if h.path == fileName {
+			// The template names a longer period than the rotation interval
+			h.rotateAt = nextPeriod(start, h.conf.rotation)
+			h.scheduleRotation()
 			return
 		}
 		if err := h.file.Close(); err != nil {
-			return
+			diag.report(h.sink(), "close", err)
 		}
+		h.file = nil
+		h.rotated = true
 	}
 
-	// Ensure the log directory exists
-	if err := os.MkdirAll(h.basePath, os.ModePerm); err != nil {
-		fprintf(os.Stderr, "Failed to create log directory %s: %v\n", h.basePath, err)
+	// After a failure, wait before trying to open the file again
+	if h.file == nil && h.failed && time.Now().Before(h.retryAt) {
 		return
 	}
 
-	// Clean up old log files
-	h.cleanOldLogs()
+	// Ensure the log directory exists
+	if err := os.MkdirAll(filepath.Dir(fileName), cmp.Or(h.conf.dirMode, defaultDirMode)); err != nil {
+		h.fail("mkdir", err)
+		return
+	}
 
 	// Open the file for writing
-	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, cmp.Or(h.conf.fileMode, defaultFileMode))
 	if err != nil {
-		fprintf(os.Stderr, "Failed to open log file %s: %v\n", fileName, err)
+		h.fail("open", err)
 		return
 	}
 
-	h.file = file
-}
+	// New encrypted files start with a header identifying the format, written
+	// once even if other processes open the file at the same time
+	if h.aead != nil {
+		if h.conf.processMode == processLock {
+			if err := lockFile(file); err != nil {
+				diag.report(h.sink(), "lock", err)
+			}
+		}
+		if stat, err := file.Stat(); err == nil && stat.Size() == 0 {
+			if err := logcrypt.NewWriter(file, h.aead).WriteHeader(); err != nil {
+				h.fail("write", err)
+				_ = file.Close()
+				return
+			}
+		}
+		if h.conf.processMode == processLock {
+			unlockFile(file)
+		}
+	}
 
-// cleanOldLogs removes log files older than the configured retention period.
-func (h *FileHandler) cleanOldLogs() {
-	if config.retentionDays <= 0 {
-		return // Retention disabled
+	h.file, h.path = file, fileName
+	h.rotateAt = nextPeriod(start, h.conf.rotation)
+	h.scheduleRotation()
+	if !h.secondary {
+		h.startCleanup()
+	}
+	if h.conf.currentLink != "" && !h.noLink {
+		h.updateCurrentLink()
 	}
+	if rotating {
+		h.rotated = false
+		metrics.Rotated(h.sink(), fileName)
+	}
+}
 
-	entries, err := os.ReadDir(h.basePath)
+// updateCurrentLink points the LOG_CURRENT_LINK symlink at the open file. The
+// link is replaced atomically, so readers always find a file behind it.
+func (h *FileHandler) updateCurrentLink() {
+	link := filepath.Join(h.basePath, h.conf.currentLink)
+	target, err := filepath.Rel(filepath.Dir(link), h.path)
 	if err != nil {
-		return
+		target = h.path
 	}
+	tmp := link + ".tmp"
+	_ = os.Remove(tmp)
+	err = os.Symlink(target, tmp)
+	if err == nil {
+		err = os.Rename(tmp, link)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		diag.report(h.sink(), "symlink", err)
+	}
+}
 
-	cutoffDate := time.Now().In(location).AddDate(0, 0, -config.retentionDays)
+// swapHandler forwards to a handler chain that can be replaced while records
+// are being logged. Loggers derived with With or WithGroup keep following it.
+type swapHandler struct {
+	current atomic.Pointer[slog.Handler]
+}
 
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
+func (h *swapHandler) set(next slog.Handler) {
+	h.current.Store(&next)
+}
 
-		// Check if filename matches the log file pattern (YYYY-MM-DD.log)
-		name := entry.Name()
-		if !strings.HasSuffix(name, ".log") {
-			continue
-		}
+func (h *swapHandler) handler() slog.Handler {
+	return *h.current.Load()
+}
 
-		// Extract date from filename
-		dateStr := strings.TrimSuffix(name, ".log")
-		fileDate, err := time.Parse("2006-01-02", dateStr)
-		if err != nil {
-			continue // Skip files that don't match the date pattern
-		}
+func (h *swapHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler().Enabled(ctx, level)
+}
 
-		// Remove file if it's older than retention period
-		if fileDate.Before(cutoffDate) {
-			filePath := filepath.Join(h.basePath, name)
-			if err := os.Remove(filePath); err != nil {
-				fprintf(os.Stderr, "Failed to remove old log file %s: %v\n", filePath, err)
-			}
-		}
-	}
+func (h *swapHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *swapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
+}
+
+func (h *swapHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
+}
+
+// lazyHandler is the root handler until Init is called with LOG_LAZY_INIT=true.
+// Logging does not run Init: a library logging during its own initialization
+// would otherwise read the .env files and create the log directory before the
+// program had a chance to configure them. Records logged before Init are
+// written to stderr at Info level and above in the default text format.
+type lazyHandler struct{}
+
+// preInitHandler writes the records logged before Init with LOG_LAZY_INIT=true.
+var preInitHandler = &ConsoleHandler{w: os.Stderr, conf: new(Config), level: slog.LevelInfo}
+
+func (lazyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return preInitHandler.Enabled(ctx, level)
+}
+
+func (lazyHandler) Handle(ctx context.Context, r slog.Record) error {
+	return preInitHandler.Handle(ctx, r)
+}
+
+func (h lazyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
+}
+
+func (h lazyHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
+}
+
+// initPending reports whether Init has yet to run with LOG_LAZY_INIT=true.
+func initPending() bool {
+	_, lazy := root.handler().(lazyHandler)
+	return lazy
 }
 
 // MultiHandler combines multiple handlers.
@@ -280,3 +548,61 @@ func (h *MultiHandler) WithGroup(name string) slog.Handler {
 	}
 	return &MultiHandler{handlers: handlers}
 }
+
+// attrsHandler adds the attributes and groups collected by WithAttrs and
+// WithGroup to every record before passing it to the wrapped handler, so that
+// the console and file handlers only have to format record attributes.
+type attrsHandler struct {
+	handler slog.Handler
+	attrs   []slog.Attr
+	prefix  string
+}
+
+func newAttrsHandler(h slog.Handler) *attrsHandler {
+	return &attrsHandler{handler: h}
+}
+
+func (h *attrsHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *attrsHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(h.qualify(a))
+		return true
+	})
+	return h.handler.Handle(ctx, nr)
+}
+
+func (h *attrsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = append(h2.attrs, h.qualify(a))
+	}
+	return &h2
+}
+
+func (h *attrsHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// qualify prefixes the attribute key with the current group names.
+func (h *attrsHandler) qualify(a slog.Attr) slog.Attr {
+	if h.prefix == "" || a.Key == "" {
+		return a
+	}
+	a.Key = h.prefix + a.Key
+	return a
+}
@@ -3,6 +3,7 @@ package log
 import (
 	"bytes"
 	"context"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -11,6 +12,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/tsisar/extended-log-go/logcrypt"
 )
 
 // testRecord creates a slog.Record with PC pointing to the caller of testRecord.
@@ -231,7 +234,7 @@ func TestFileHandler_Handle(t *testing.T) {
 	}
 
 	// Read the log file
-	today := time.Now().In(location).Format("2006-01-02") + ".log"
+	today := time.Now().In(config.loc()).Format("2006-01-02") + ".log"
 	data, err := os.ReadFile(filepath.Join(dir, today))
 	if err != nil {
 		t.Fatalf("could not read log file: %v", err)
@@ -255,7 +258,7 @@ func TestFileHandler_WithCaller(t *testing.T) {
 		t.Fatalf("Handle() error: %v", err)
 	}
 
-	today := time.Now().In(location).Format("2006-01-02") + ".log"
+	today := time.Now().In(config.loc()).Format("2006-01-02") + ".log"
 	data, err := os.ReadFile(filepath.Join(dir, today))
 	if err != nil {
 		t.Fatalf("could not read log file: %v", err)
@@ -271,9 +274,9 @@ func TestFileHandler_WithCaller(t *testing.T) {
 
 func TestCleanOldLogs(t *testing.T) {
 	dir := t.TempDir()
-	origRetention := config.retentionDays
-	config.retentionDays = 7
-	defer func() { config.retentionDays = origRetention }()
+	origRetention := config.retention
+	config.retention = 7 * day
+	defer func() { config.retention = origRetention }()
 
 	// Create old and fresh log files
 	oldDate := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
@@ -283,7 +286,7 @@ func TestCleanOldLogs(t *testing.T) {
 	os.WriteFile(filepath.Join(dir, freshDate+".log"), []byte("fresh"), 0666)
 	os.WriteFile(filepath.Join(dir, "not-a-date.log"), []byte("other"), 0666)
 
-	h := &FileHandler{basePath: dir}
+	h := &FileHandler{basePath: dir, conf: &config}
 	h.cleanOldLogs()
 
 	// Old file should be removed
@@ -298,4 +301,101 @@ func TestCleanOldLogs(t *testing.T) {
 	if _, err := os.Stat(filepath.Join(dir, "not-a-date.log")); err != nil {
 		t.Error("non-date log file should still exist")
 	}
-}
\ No newline at end of file
+}
+
+func TestFileHandler_Encrypted(t *testing.T) {
+	logLevel.Set(slog.LevelInfo)
+	dir := t.TempDir()
+	aead, err := logcrypt.NewAEAD(bytes.Repeat([]byte{0x07}, 32))
+	if err != nil {
+		t.Fatalf("NewAEAD() error: %v", err)
+	}
+	h := newEncryptedFileHandler(dir, aead)
+	defer func() { _ = h.Close() }()
+
+	origShowCaller := config.showCaller
+	config.showCaller = false
+	defer func() { config.showCaller = origShowCaller }()
+
+	for _, msg := range []string{"secret one", "secret two"} {
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, msg, 0)
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	name := filepath.Join(dir, time.Now().In(config.loc()).Format("2006-01-02")+".log"+logcrypt.Extension)
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("could not read encrypted log file: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatal("encrypted log file contains plaintext")
+	}
+
+	plain, err := io.ReadAll(logcrypt.NewReader(bytes.NewReader(data), aead))
+	if err != nil {
+		t.Fatalf("decrypt error: %v", err)
+	}
+	if !strings.Contains(string(plain), "secret one") || !strings.Contains(string(plain), "secret two") {
+		t.Errorf("decrypted log does not contain messages, got: %s", plain)
+	}
+}
+
+func TestCleanOldLogs_Encrypted(t *testing.T) {
+	dir := t.TempDir()
+	origRetention := config.retention
+	config.retention = 7 * day
+	defer func() { config.retention = origRetention }()
+
+	oldName := time.Now().AddDate(0, 0, -10).Format("2006-01-02") + ".log" + logcrypt.Extension
+	freshName := time.Now().Format("2006-01-02") + ".log" + logcrypt.Extension
+
+	os.WriteFile(filepath.Join(dir, oldName), []byte("old"), 0666)
+	os.WriteFile(filepath.Join(dir, freshName), []byte("fresh"), 0666)
+
+	h := &FileHandler{basePath: dir, conf: &config}
+	h.cleanOldLogs()
+
+	if _, err := os.Stat(filepath.Join(dir, oldName)); !os.IsNotExist(err) {
+		t.Error("old encrypted log file should have been removed")
+	}
+	if _, err := os.Stat(filepath.Join(dir, freshName)); err != nil {
+		t.Error("fresh encrypted log file should still exist")
+	}
+}
+
+// loggingValuer logs through the package-level logger when it is resolved.
+type loggingValuer struct{}
+
+func (loggingValuer) LogValue() slog.Value {
+	Info("resolving value")
+	return slog.StringValue("resolved")
+}
+
+func TestFileHandler_ReentrantLogValuer(t *testing.T) {
+	setupReload(t)
+	dir := t.TempDir()
+	writeFile(t, ".env", "LOG_SAVE=true\nLOG_DIRECTORY="+dir+"\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	logger = slog.New(fileHandler)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Info("outer", "value", loggingValuer{})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a LogValuer logging through the file sink deadlocked")
+	}
+	fileHandler.Close()
+
+	data, _ := os.ReadFile(filepath.Join(dir, time.Now().In(config.loc()).Format("2006-01-02")+".log"))
+	if !strings.Contains(string(data), "resolving value") || !strings.Contains(string(data), "outer | value=resolved") {
+		t.Errorf("log file = %q", data)
+	}
+}
@@ -0,0 +1,80 @@
+package log
+
+import (
+	"crypto/cipher"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/tsisar/extended-log-go/logcrypt"
+)
+
+// ConfigFromEnvPrefix reads a configuration from the variables named with
+// prefix instead of LOG_, e.g. BILLING_LOG_LEVEL and BILLING_LOG_DIRECTORY for
+// the prefix "BILLING_LOG_". Values from the configuration files are used
+// once the package-level logger has been initialized. Invalid values are
+// replaced by their defaults and reported by Validate.
+func ConfigFromEnvPrefix(prefix string) Config {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return loadConfig(prefix)
+}
+
+// Instance is a logger with its own configuration and sinks, independent of
+// the package-level logger. Reload does not affect it.
+type Instance struct {
+	*slog.Logger
+	config    Config
+	files     []*FileHandler
+	forwarder *ForwardHandler
+}
+
+// New returns a logger writing to stdout and, when saving is enabled, to
+// files in the configured directory, encrypted if prefix+"ENCRYPTION_KEY" is
+// set, or to the writer on the socket in forward mode. Writer mode only
+// applies to the package-level logger. If the configuration enables strict
+// mode and is invalid, New returns the problems instead. Instances must not
+// share a directory unless a process mode allows it.
+func New(cfg Config) (*Instance, error) {
+	if err := cfg.Validate(); err != nil && cfg.strict {
+		return nil, err
+	}
+
+	i := &Instance{config: cfg}
+	handlers := []slog.Handler{&ConsoleHandler{w: os.Stdout, conf: &i.config, level: cfg.level}}
+	if cfg.save && cfg.processMode == processForward {
+		i.forwarder = newForwardHandler(&i.config, cfg.level, cfg.socket)
+		handlers = append(handlers, i.forwarder)
+	} else if cfg.save {
+		var aead cipher.AEAD
+		if s := getenv(cfg.prefix + "ENCRYPTION_KEY"); s != "" {
+			key, err := logcrypt.ParseKey(s)
+			if err != nil {
+				return nil, err
+			}
+			if aead, err = logcrypt.NewAEAD(key); err != nil {
+				return nil, err
+			}
+		}
+		i.files = newFileSinkHandlers(&i.config, cfg.level, cfg.directory, aead)
+		for _, h := range i.files {
+			handlers = append(handlers, h)
+		}
+	}
+	i.Logger = slog.New(newMultiHandler(handlers...))
+	return i, nil
+}
+
+// Close closes the log files of the instance, or its connection to the writer.
+func (i *Instance) Close() error {
+	if i.forwarder != nil {
+		return i.forwarder.Close()
+	}
+	var errs []error
+	for _, h := range i.files {
+		errs = append(errs, h.Close())
+	}
+	return errors.Join(errs...)
+}
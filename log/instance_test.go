@@ -0,0 +1,105 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnvPrefix(t *testing.T) {
+	t.Setenv("BILLING_LOG_LEVEL", "warn")
+	t.Setenv("BILLING_LOG_FORMAT", "json")
+	t.Setenv("BILLING_LOG_SHOW_CALLER", "maybe")
+	setupReload(t)
+
+	cfg := ConfigFromEnvPrefix("BILLING_LOG")
+	if cfg.level != slog.LevelWarn || cfg.format != formatJSON {
+		t.Errorf("level = %v, format = %s", cfg.level, cfg.format)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "BILLING_LOG_SHOW_CALLER") {
+		t.Errorf("Validate() = %v, want the prefixed variable", err)
+	}
+}
+
+func TestNew_IndependentInstances(t *testing.T) {
+	billingDir, ordersDir := t.TempDir(), t.TempDir()
+	t.Setenv("BILLING_LOG_SAVE", "true")
+	t.Setenv("BILLING_LOG_LEVEL", "warn")
+	t.Setenv("BILLING_LOG_DIRECTORY", billingDir)
+	t.Setenv("ORDERS_LOG_SAVE", "true")
+	t.Setenv("ORDERS_LOG_LEVEL", "debug")
+	t.Setenv("ORDERS_LOG_FORMAT", "logfmt")
+	t.Setenv("ORDERS_LOG_DIRECTORY", ordersDir)
+	setupReload(t)
+
+	billing, err := New(ConfigFromEnvPrefix("BILLING_LOG_"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer func() { _ = billing.Close() }()
+	orders, err := New(ConfigFromEnvPrefix("ORDERS_LOG_"))
+	if err != nil {
+		t.Fatalf("New() error: %v", err)
+	}
+	defer func() { _ = orders.Close() }()
+
+	billing.Info("billing info")
+	billing.Warn("billing warn")
+	orders.Debug("orders debug", "id", 7)
+
+	name := time.Now().Format("2006-01-02") + ".log"
+	data, _ := os.ReadFile(filepath.Join(billingDir, name))
+	if got := string(data); strings.Contains(got, "billing info") || !strings.Contains(got, "billing warn") || strings.Contains(got, "orders") {
+		t.Errorf("billing log = %q", got)
+	}
+	data, _ = os.ReadFile(filepath.Join(ordersDir, name))
+	if got := string(data); !strings.Contains(got, `level=DEBUG`) || !strings.Contains(got, `msg="orders debug" id=7`) {
+		t.Errorf("orders log = %q", got)
+	}
+}
+
+func TestNew_StrictRejectsInvalidConfig(t *testing.T) {
+	t.Setenv("APP_LOG_STRICT", "true")
+	t.Setenv("APP_LOG_RETENTION_DAYS", "-1")
+	setupReload(t)
+
+	if _, err := New(ConfigFromEnvPrefix("APP_LOG_")); err == nil {
+		t.Error("New() should reject an invalid configuration in strict mode")
+	}
+}
+
+func TestLazyInit(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "error")
+	setupReload(t)
+	initOnce = sync.Once{}
+	root.set(lazyHandler{})
+
+	var stderr bytes.Buffer
+	preInitHandler.w = &stderr
+	defer func() { preInitHandler.w = os.Stderr }()
+
+	l := slog.New(root)
+	if !l.Enabled(context.Background(), slog.LevelWarn) || l.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("records before Init should be written at Info level and above")
+	}
+	l.Warn("before init", "k", "v")
+	if !initPending() {
+		t.Fatal("logging should not run Init")
+	}
+	if out := stderr.String(); !strings.Contains(out, "WARN") || !strings.Contains(out, "| before init | k=v") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+
+	if err := Init(); err != nil {
+		t.Fatalf("Init() error: %v", err)
+	}
+	if initPending() || l.Enabled(context.Background(), slog.LevelWarn) {
+		t.Errorf("Init should apply LOG_LEVEL=error, level = %v", config.level)
+	}
+}
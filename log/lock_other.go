@@ -0,0 +1,14 @@
+//go:build !unix
+
+package log
+
+import "os"
+
+// Advisory locks are only available on Unix; elsewhere LOG_PROCESS_MODE=lock
+// behaves like shared.
+
+func lockFile(*os.File) error { return nil }
+
+func tryLockFile(*os.File) (bool, error) { return true, nil }
+
+func unlockFile(*os.File) {}
@@ -9,6 +9,27 @@ import (
 	"time"
 )
 
+// Logger returns the *slog.Logger used by the package-level functions.
+// Its handler applies the configured level, format and sinks.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// SetLogger replaces the logger used by the package-level functions. It is
+// meant for tests and for programs that build their own handler chain; it is
+// not safe to call while other goroutines are logging.
+func SetLogger(l *slog.Logger) {
+	if l != nil {
+		logger = l
+	}
+}
+
+// Level returns the minimum level of the console and file handlers, as set by
+// LOG_LEVEL. The returned Leveler reflects later changes of the level.
+func Level() slog.Leveler {
+	return logLevel
+}
+
 // logWithPC captures the caller's PC and sends the record directly to the handler,
 // bypassing slog.Logger's internal PC capture which would point to this package.
 func logWithPC(level slog.Level, msg string) {
@@ -97,4 +118,4 @@ func Trace(msg string) {
 // Tracef logs a formatted trace message.
 func Tracef(format string, args ...interface{}) {
 	logWithPC(LevelTrace, fmt.Sprintf(format, args...))
-}
\ No newline at end of file
+}
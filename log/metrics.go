@@ -0,0 +1,173 @@
+package log
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Sink names passed to Metrics. The files of LOG_FILES report as SinkFile
+// followed by ":" and the name of the file, e.g. "file:errors".
+const (
+	SinkConsole = "console"
+	SinkFile    = "file"
+	SinkForward = "forward" // the file sink in LOG_PROCESS_MODE=forward
+)
+
+// Metrics receives events from the console and file handlers. Implementations
+// are called on every record and must be fast and safe for concurrent use.
+type Metrics interface {
+	// Written is called after a sink wrote a record of n bytes.
+	Written(sink string, level slog.Level, n int)
+	// Dropped is called when a sink could not write a record.
+	Dropped(sink string, level slog.Level)
+	// WriteError is called when writing to a sink, or opening or cleaning up
+	// its files, fails.
+	WriteError(sink string, err error)
+	// Rotated is called when the file sink switches to a new file.
+	Rotated(sink string, file string)
+}
+
+// metrics passes handler events to the Metrics installed with SetMetrics.
+var metrics = new(metricsSink)
+
+// SetMetrics installs m to receive handler events; nil disables metrics.
+func SetMetrics(m Metrics) {
+	if m == nil {
+		m = noMetrics{}
+	}
+	metrics.m.Store(&m)
+}
+
+// metricsSink loads the installed Metrics atomically, since SetMetrics may
+// run while the handlers, the cleanup and the rotation timer report events.
+type metricsSink struct {
+	m atomic.Pointer[Metrics]
+}
+
+func (s *metricsSink) get() Metrics {
+	if m := s.m.Load(); m != nil {
+		return *m
+	}
+	return noMetrics{}
+}
+
+func (s *metricsSink) Written(sink string, level slog.Level, n int) {
+	s.get().Written(sink, level, n)
+}
+
+func (s *metricsSink) Dropped(sink string, level slog.Level) {
+	s.get().Dropped(sink, level)
+}
+
+func (s *metricsSink) WriteError(sink string, err error) {
+	s.get().WriteError(sink, err)
+}
+
+func (s *metricsSink) Rotated(sink string, file string) {
+	s.get().Rotated(sink, file)
+}
+
+// reportWrite reports the outcome of a sink writing a record.
+func reportWrite(sink string, level slog.Level, n int, err error) {
+	if err != nil {
+		metrics.WriteError(sink, err)
+		metrics.Dropped(sink, level)
+		return
+	}
+	metrics.Written(sink, level, n)
+}
+
+type noMetrics struct{}
+
+func (noMetrics) Written(string, slog.Level, int) {}
+func (noMetrics) Dropped(string, slog.Level)      {}
+func (noMetrics) WriteError(string, error)        {}
+func (noMetrics) Rotated(string, string)          {}
+
+// ExpvarMetrics counts handler events in expvar maps, which can be read at
+// /debug/vars or served in the Prometheus text format by PrometheusHandler.
+type ExpvarMetrics struct {
+	records   *expvar.Map // keyed by "sink.LEVEL"
+	bytes     *expvar.Map // keyed by sink, like the maps below
+	dropped   *expvar.Map
+	errors    *expvar.Map
+	rotations *expvar.Map
+}
+
+// NewExpvarMetrics returns an ExpvarMetrics published as the expvar variable
+// name, with the maps records, bytes, dropped, errors and rotations. An empty
+// name or a name that is already published leaves the counters unpublished.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	m := &ExpvarMetrics{
+		records:   new(expvar.Map),
+		bytes:     new(expvar.Map),
+		dropped:   new(expvar.Map),
+		errors:    new(expvar.Map),
+		rotations: new(expvar.Map),
+	}
+	if name != "" && expvar.Get(name) == nil {
+		v := expvar.NewMap(name)
+		v.Set("records", m.records)
+		v.Set("bytes", m.bytes)
+		v.Set("dropped", m.dropped)
+		v.Set("errors", m.errors)
+		v.Set("rotations", m.rotations)
+	}
+	return m
+}
+
+func (m *ExpvarMetrics) Written(sink string, level slog.Level, n int) {
+	m.records.Add(sink+"."+levelText(level), 1)
+	m.bytes.Add(sink, int64(n))
+}
+
+func (m *ExpvarMetrics) Dropped(sink string, _ slog.Level) {
+	m.dropped.Add(sink, 1)
+}
+
+func (m *ExpvarMetrics) WriteError(sink string, _ error) {
+	m.errors.Add(sink, 1)
+}
+
+func (m *ExpvarMetrics) Rotated(sink string, _ string) {
+	m.rotations.Add(sink, 1)
+}
+
+// PrometheusHandler returns an http.Handler serving the counters in the
+// Prometheus text exposition format.
+func (m *ExpvarMetrics) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		fmt.Fprintf(w, "# HELP log_records_total Log records written, by sink and level.\n")
+		fmt.Fprintf(w, "# TYPE log_records_total counter\n")
+		m.records.Do(func(kv expvar.KeyValue) {
+			i := strings.LastIndexByte(kv.Key, '.')
+			fmt.Fprintf(w, "log_records_total{%s,level=%q} %s\n", sinkLabels(kv.Key[:i]), kv.Key[i+1:], kv.Value)
+		})
+		writePrometheusCounter(w, "log_bytes_total", "Bytes of log records written, by sink.", m.bytes)
+		writePrometheusCounter(w, "log_dropped_records_total", "Log records a sink could not write, by sink.", m.dropped)
+		writePrometheusCounter(w, "log_write_errors_total", "Errors writing to or managing the files of a sink, by sink.", m.errors)
+		writePrometheusCounter(w, "log_rotations_total", "Switches to a new log file, by sink.", m.rotations)
+	})
+}
+
+func writePrometheusCounter(w http.ResponseWriter, name, help string, values *expvar.Map) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	values.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, sinkLabels(kv.Key), kv.Value)
+	})
+}
+
+// sinkLabels returns the labels of a sink: sink="file",name="errors" for the
+// files of LOG_FILES and sink="console" for the others.
+func sinkLabels(sink string) string {
+	if sink, name, ok := strings.Cut(sink, ":"); ok {
+		return fmt.Sprintf("sink=%q,name=%q", sink, name)
+	}
+	return fmt.Sprintf("sink=%q", sink)
+}
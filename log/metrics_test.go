@@ -0,0 +1,105 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpvarMetrics_CountsHandlerEvents(t *testing.T) {
+	m := NewExpvarMetrics("")
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	ctx := context.Background()
+	var buf bytes.Buffer
+	console := newConsoleHandler(&buf)
+	_ = console.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "one", 0))
+	_ = console.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelError, "two", 0))
+
+	// A regular file where the log directory should be makes the file sink fail
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setupDiagnostics(t)
+	file := newFileHandler(filepath.Join(blocker, "logs"))
+	file.fallback = nil
+	_ = file.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelWarn, "lost", 0))
+
+	if got := m.records.Get("console.INFO").String(); got != "1" {
+		t.Errorf("console.INFO = %s, want 1", got)
+	}
+	if got := m.bytes.Get(SinkConsole).String(); got != strconv.Itoa(buf.Len()) {
+		t.Errorf("console bytes = %s, want %d", got, buf.Len())
+	}
+	if got := m.dropped.Get(SinkFile).String(); got != "1" {
+		t.Errorf("file dropped = %s, want 1", got)
+	}
+	if m.errors.Get(SinkFile) == nil {
+		t.Error("file errors should be counted")
+	}
+
+	rec := httptest.NewRecorder()
+	m.PrometheusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE log_records_total counter",
+		`log_records_total{sink="console",level="ERROR"} 1`,
+		`log_dropped_records_total{sink="file"} 1`,
+		`log_write_errors_total{sink="file"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition should contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestExpvarMetrics_LabelsNamedFiles(t *testing.T) {
+	m := NewExpvarMetrics("")
+	SetMetrics(m)
+	defer SetMetrics(nil)
+
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setupDiagnostics(t)
+	file := newFileHandler(filepath.Join(blocker, "logs"))
+	file.name, file.fallback = "errors", nil
+	_ = file.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelError, "lost", 0))
+
+	if got := m.dropped.Get("file:errors"); got == nil || got.String() != "1" {
+		t.Errorf("file:errors dropped = %v, want 1", got)
+	}
+
+	rec := httptest.NewRecorder()
+	m.PrometheusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if want := `log_dropped_records_total{sink="file",name="errors"} 1`; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("exposition should contain %q, got:\n%s", want, rec.Body.String())
+	}
+}
+
+func TestSetMetrics_Concurrent(t *testing.T) {
+	defer SetMetrics(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			SetMetrics(NewExpvarMetrics(""))
+		}
+	}()
+	h := newConsoleHandler(io.Discard)
+	for range 100 {
+		_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "x", 0))
+	}
+	<-done
+}
@@ -0,0 +1,275 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Ways of sharing the log directory with other processes (LOG_PROCESS_MODE).
+const (
+	processShared  = "shared"  // every process appends to the same files
+	processLock    = "lock"    // writes and cleanups take advisory locks
+	processPID     = "pid"     // every process writes its own files
+	processWriter  = "writer"  // writes the records forwarded to LOG_SOCKET
+	processForward = "forward" // sends file records to the writer on LOG_SOCKET
+)
+
+// maxForwardedRecord limits the size of a record received from another process.
+const maxForwardedRecord = 16 << 20
+
+// parseProcessMode validates a LOG_PROCESS_MODE value.
+func parseProcessMode(s string) (string, bool) {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "", processShared:
+		return processShared, true
+	case processLock, processPID, processWriter, processForward:
+		return m, true
+	}
+	return processShared, false
+}
+
+// forwardWriteTimeout limits the time a record may take to reach the writer,
+// so that a writer that stopped reading does not block the logging process.
+const forwardWriteTimeout = time.Second
+
+// ForwardHandler is the file sink in LOG_PROCESS_MODE=forward. It sends the
+// formatted records to the process listening on LOG_SOCKET, which writes them
+// to its log file. While the socket cannot be reached, records go to the
+// fallback writer and connecting is retried with increasing delays.
+type ForwardHandler struct {
+	socket     string
+	conf       *Config
+	level      slog.Leveler
+	conn       net.Conn
+	fallback   io.Writer
+	failed     bool
+	retryAt    time.Time
+	retryDelay time.Duration
+	closed     bool
+	mu         sync.Mutex
+}
+
+func newForwardHandler(conf *Config, level slog.Leveler, socket string) *ForwardHandler {
+	h := &ForwardHandler{socket: socket, conf: conf, level: level}
+	if conf.fileFallback {
+		h.fallback = os.Stderr
+	}
+	return h
+}
+
+func (h *ForwardHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *ForwardHandler) Handle(_ context.Context, r slog.Record) error {
+	message := formatRecord(h.conf, r, false)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		// Replaced by a reload while the record was on its way
+		h.writeFallback(r.Level, message)
+		return nil
+	}
+	if h.conn == nil {
+		if h.failed && time.Now().Before(h.retryAt) {
+			h.writeFallback(r.Level, message)
+			return nil
+		}
+		conn, err := net.DialTimeout("unix", h.socket, time.Second)
+		if err != nil {
+			h.fail("connect", err)
+			h.writeFallback(r.Level, message)
+			return nil
+		}
+		h.conn = conn
+	}
+
+	// A frame is the length of the record, its level and the record
+	frame := make([]byte, 8, 8+len(message))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(message)))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(int32(r.Level)))
+	frame = append(frame, message...)
+	err := h.conn.SetWriteDeadline(time.Now().Add(forwardWriteTimeout))
+	if err == nil {
+		_, err = h.conn.Write(frame)
+	}
+	// A frame written in part cannot be completed, the connection is dropped
+	reportWrite(SinkForward, r.Level, len(message), err)
+	if err != nil {
+		h.fail("write", err)
+		_ = h.conn.Close()
+		h.conn = nil
+		h.writeFallback(r.Level, message)
+		return err
+	}
+	if h.failed {
+		h.failed, h.retryDelay = false, 0
+		diag.recovered(SinkForward)
+	}
+	return nil
+}
+
+func (h *ForwardHandler) writeFallback(level slog.Level, message []byte) {
+	metrics.Dropped(SinkForward, level)
+	if h.fallback != nil {
+		_, _ = h.fallback.Write(message)
+	}
+}
+
+// fail reports a failure to reach the writer and delays the next attempt.
+func (h *ForwardHandler) fail(op string, err error) {
+	metrics.WriteError(SinkForward, err)
+	diag.report(SinkForward, op, err)
+
+	h.failed = true
+	h.retryDelay = min(max(2*h.retryDelay, minRetryDelay), maxRetryDelay)
+	h.retryAt = time.Now().Add(h.retryDelay)
+}
+
+func (h *ForwardHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
+}
+
+func (h *ForwardHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
+}
+
+// Close closes the connection to the writer. Later records go to the
+// fallback writer.
+func (h *ForwardHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+// forwardServer receives records from processes in LOG_PROCESS_MODE=forward
+// and writes them to the file sink of the writer process.
+type forwardServer struct {
+	socket   string
+	listener net.Listener
+	target   atomic.Pointer[[]*FileHandler]
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+}
+
+// listenForward listens on socket, replacing a socket file left behind by a
+// writer that has exited.
+func listenForward(socket string) (*forwardServer, error) {
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("%s is in use by another writer", socket)
+	}
+	_ = os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &forwardServer{socket: socket, listener: listener, conns: make(map[net.Conn]bool)}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *forwardServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return // closed
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.conns[conn] = true
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.receive(conn)
+	}
+}
+
+// receive writes the records sent over conn until it is closed.
+func (s *forwardServer) receive(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header[0:4])
+		level := slog.Level(int32(binary.BigEndian.Uint32(header[4:8])))
+		if size > maxForwardedRecord {
+			diag.report(SinkFile, "receive", errors.New("forwarded record too large"))
+			return
+		}
+		message := make([]byte, size)
+		if _, err := io.ReadFull(r, message); err != nil {
+			return
+		}
+		s.write(level, message)
+	}
+}
+
+// write writes a forwarded record to the files whose LOG_FILES level range
+// includes it; without LOG_FILES, the record is written to the log file.
+func (s *forwardServer) write(level slog.Level, message []byte) {
+	written := false
+	if targets := s.target.Load(); targets != nil {
+		for _, h := range *targets {
+			if h.levels == nil || h.levels.contains(level) {
+				_ = h.writeForwarded(level, message)
+				written = true
+			}
+		}
+	}
+	if !written {
+		metrics.Dropped(SinkFile, level)
+	}
+}
+
+// Close stops listening, closes the connections and waits for the records
+// being received to be written.
+func (s *forwardServer) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
@@ -0,0 +1,199 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_ProcessMode(t *testing.T) {
+	t.Setenv("LOG_PROCESS_MODE", "PID")
+	t.Setenv("LOG_FILE_NAME", "")
+	setupReload(t)
+
+	cfg := loadConfig("LOG_")
+	if cfg.processMode != processPID || cfg.fileName != "{date}-{pid}.log" {
+		t.Errorf("process mode = %s, file name = %s", cfg.processMode, cfg.fileName)
+	}
+	if name := cfg.fileTemplate().Expand(time.Now()); !strings.Contains(name, "-"+strconv.Itoa(os.Getpid())+".log") {
+		t.Errorf("file name %s should contain the pid", name)
+	}
+
+	t.Setenv("LOG_PROCESS_MODE", "forward")
+	t.Setenv("LOG_SOCKET", "")
+	cfg = loadConfig("LOG_")
+	if err := cfg.Validate(); cfg.processMode != processShared || err == nil || !strings.Contains(err.Error(), "requires LOG_SOCKET") {
+		t.Errorf("process mode = %s, Validate() = %v", cfg.processMode, err)
+	}
+}
+
+// socketPath returns a path for a Unix socket short enough for every platform.
+func socketPath(t *testing.T) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, "log.sock")
+}
+
+func TestForwardHandler(t *testing.T) {
+	withFormat(t, formatText, false)
+	socket := socketPath(t)
+	dir := t.TempDir()
+
+	server, err := listenForward(socket)
+	if err != nil {
+		t.Fatalf("listenForward() error: %v", err)
+	}
+	file := &FileHandler{basePath: dir, conf: &config}
+	server.target.Store(&[]*FileHandler{file})
+	if _, err := listenForward(socket); err == nil {
+		t.Error("a second writer should not take over the socket")
+	}
+
+	child := newForwardHandler(&config, slog.LevelInfo, socket)
+	for _, msg := range []string{"from child", "line one\nline two"} {
+		r := slog.NewRecord(time.Now(), slog.LevelWarn, msg, 0)
+		if err := child.Handle(context.Background(), r); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+	child.Close()
+
+	// Wait for the writer to write what the child sent
+	name := filepath.Join(dir, time.Now().In(config.loc()).Format("2006-01-02")+".log")
+	var content string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		data, _ := os.ReadFile(name)
+		if content = string(data); strings.Contains(content, "line two") {
+			break
+		}
+	}
+	server.Close()
+	file.Close()
+
+	if !strings.Contains(content, "WARN") || !strings.Contains(content, "from child") || !strings.Contains(content, "line two") {
+		t.Errorf("log file = %q", content)
+	}
+}
+
+func TestForwardHandler_Fallback(t *testing.T) {
+	withFormat(t, formatText, false)
+	setupDiagnostics(t)
+
+	var fallback bytes.Buffer
+	child := newForwardHandler(&config, slog.LevelInfo, socketPath(t))
+	child.fallback = &fallback
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "nobody listening", 0)
+	if err := child.Handle(context.Background(), r); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if !strings.Contains(fallback.String(), "nobody listening") || !child.failed {
+		t.Errorf("fallback = %q, failed = %v", fallback.String(), child.failed)
+	}
+}
+
+func TestForwardHandler_WriterNotReading(t *testing.T) {
+	withFormat(t, formatText, false)
+	out, _ := setupDiagnostics(t)
+	socket := socketPath(t)
+
+	// A writer that accepts connections but never reads from them
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	var fallback bytes.Buffer
+	child := newForwardHandler(&config, slog.LevelInfo, socket)
+	child.fallback = &fallback
+	defer child.Close()
+
+	// Records are written until the socket buffer is full and a write times out
+	big := strings.Repeat("x", 64<<10)
+	start := time.Now()
+	for i := 0; i < 1000 && fallback.Len() == 0; i++ {
+		_ = child.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, big, 0))
+	}
+	if elapsed := time.Since(start); elapsed > 5*forwardWriteTimeout {
+		t.Errorf("Handle blocked for %v", elapsed)
+	}
+	if !strings.Contains(fallback.String(), big) || !child.failed || child.conn != nil {
+		t.Errorf("the record should go to the fallback, failed = %v", child.failed)
+	}
+	if !strings.Contains(out.String(), "op=write") || !strings.Contains(out.String(), "timeout") {
+		t.Errorf("diagnostics = %q", out.String())
+	}
+}
+
+func TestTryLockFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("advisory locks are only used on Unix")
+	}
+	name := filepath.Join(t.TempDir(), cleanupLockName)
+	first, _ := os.Create(name)
+	defer first.Close()
+	second, _ := os.Open(name)
+	defer second.Close()
+
+	if ok, err := tryLockFile(first); !ok || err != nil {
+		t.Fatalf("tryLockFile() = %v, %v", ok, err)
+	}
+	if ok, _ := tryLockFile(second); ok {
+		t.Error("a second lock on the file should fail")
+	}
+
+	// The cleanup is left to the process holding the lock
+	dir := filepath.Dir(name)
+	paths := writeLogFiles(t, dir, 3)
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxFiles, conf.processMode = 0, 1, processLock
+	(&FileHandler{basePath: dir, conf: conf}).cleanOldLogs()
+	if !exists(paths[0]) {
+		t.Error("the cleanup should be skipped while another process runs it")
+	}
+
+	unlockFile(first)
+	(&FileHandler{basePath: dir, conf: conf}).cleanOldLogs()
+	if exists(paths[0]) || exists(paths[1]) {
+		t.Error("the cleanup should run once the lock is released")
+	}
+}
+
+func TestFileHandler_LockMode(t *testing.T) {
+	withFormat(t, formatText, false)
+	dir := t.TempDir()
+	conf := fileConfig(t, "{date}.log")
+	conf.processMode = processLock
+	h := &FileHandler{basePath: dir, conf: conf, level: slog.LevelInfo}
+	defer h.Close()
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "locked write", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	data, _ := os.ReadFile(h.path)
+	if !strings.Contains(string(data), "locked write") {
+		t.Errorf("log file = %q", data)
+	}
+}
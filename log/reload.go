@@ -0,0 +1,425 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// envFileNone disables reading .env files when LOG_ENV_FILE is set to it.
+const envFileNone = "none"
+
+// defaultWatchInterval is how often WatchConfig checks the files for changes.
+const defaultWatchInterval = 2 * time.Second
+
+// baseEnv holds the names of the variables set in the process environment
+// at startup. They take precedence over the configuration files.
+var baseEnv map[string]bool
+
+// fileValues holds the variables read from the configuration files, except
+// for those in baseEnv.
+var fileValues = make(map[string]string)
+
+// fileSources holds the name of the file each variable in fileValues was read from.
+var fileSources = make(map[string]string)
+
+// fileMu guards fileValues and fileSources, which a reload replaces while
+// the configuration may be read, e.g. by ConfigFromEnvPrefix or a key provider.
+var fileMu sync.RWMutex
+
+// fileEnv holds the names of the variables exported from configuration files.
+var fileEnv = make(map[string]bool)
+
+// reloadMu serializes Init, reloads and SetKeyProvider, which set up the
+// handlers and replace the handler globals.
+var reloadMu sync.Mutex
+
+func environ() map[string]bool {
+	names := make(map[string]bool)
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		names[name] = true
+	}
+	return names
+}
+
+// getenv returns the value of a configuration variable: from the process
+// environment if it was set there at startup, otherwise from the
+// configuration files, otherwise from the current environment.
+func getenv(key string) string {
+	fileMu.RLock()
+	v, ok := fileValues[key]
+	fileMu.RUnlock()
+	if ok {
+		return v
+	}
+	return os.Getenv(key)
+}
+
+// envFilesDisabled reports whether LOG_ENV_FILE=none is set. The .env files
+// are then not read and the environment is not modified.
+func envFilesDisabled() bool {
+	return os.Getenv("LOG_ENV_FILE") == envFileNone
+}
+
+// envFiles returns the .env files in increasing order of precedence: the
+// comma-separated LOG_ENV_FILE list, or .env, .env.$APP_ENV, .env.local and
+// .env.$APP_ENV.local.
+func envFiles() []string {
+	if envFilesDisabled() {
+		return nil
+	}
+	if list := os.Getenv("LOG_ENV_FILE"); list != "" {
+		var files []string
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				files = append(files, name)
+			}
+		}
+		return files
+	}
+	appEnv := os.Getenv("APP_ENV")
+	if appEnv == "" {
+		return []string{".env", ".env.local"}
+	}
+	return []string{".env", ".env." + appEnv, ".env.local", ".env." + appEnv + ".local"}
+}
+
+// configFiles returns the configuration files in increasing order of precedence.
+func configFiles() []string {
+	files := envFiles()
+	if name := getenv("LOG_CONFIG"); name != "" {
+		files = append(files, name)
+	}
+	return files
+}
+
+// loadConfigFiles reads the configuration files and commits their values.
+func loadConfigFiles() error {
+	values, sources, err := readConfigFiles()
+	if err != nil {
+		return err
+	}
+	commitConfigFiles(values, sources)
+	return nil
+}
+
+// readConfigFiles reads the .env files and the LOG_CONFIG file, ignoring
+// variables set in the process environment. It returns the values and the
+// file each was read from.
+func readConfigFiles() (values, sources map[string]string, err error) {
+	values = make(map[string]string)
+	sources = make(map[string]string)
+	// Variables are expanded with the effective values of earlier files
+	lookup := func(key string) (string, bool) {
+		if !baseEnv[key] {
+			if v, ok := values[key]; ok {
+				return v, true
+			}
+			if fileEnv[key] {
+				return "", false
+			}
+		}
+		return os.LookupEnv(key)
+	}
+	for _, name := range envFiles() {
+		fv, err := readEnvFile(name, lookup)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range fv {
+			values[k], sources[k] = v, name
+		}
+	}
+
+	// LOG_CONFIG may itself be set in a .env file
+	name := values["LOG_CONFIG"]
+	if baseEnv["LOG_CONFIG"] {
+		name = os.Getenv("LOG_CONFIG")
+	}
+	if name != "" {
+		fv, err := readConfigFile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range fv {
+			values[k], sources[k] = v, name
+		}
+	}
+
+	for k := range values {
+		if baseEnv[k] {
+			delete(values, k)
+			delete(sources, k)
+		}
+	}
+	return values, sources, nil
+}
+
+// fileGetenv returns the getenv that applies once values are committed.
+func fileGetenv(values map[string]string) func(string) string {
+	return func(key string) string {
+		if v, ok := values[key]; ok {
+			return v
+		}
+		if fileEnv[key] {
+			return ""
+		}
+		return os.Getenv(key)
+	}
+}
+
+// commitConfigFiles makes values the variables read from the configuration
+// files and exports them to the environment unless .env files are disabled.
+// Variables exported by an earlier call that are no longer in the files are
+// removed.
+func commitConfigFiles(values, sources map[string]string) {
+	fileMu.Lock()
+	fileValues, fileSources = values, sources
+	fileMu.Unlock()
+
+	for k := range fileEnv {
+		if _, ok := values[k]; !ok || envFilesDisabled() {
+			_ = os.Unsetenv(k)
+			delete(fileEnv, k)
+		}
+	}
+	if envFilesDisabled() {
+		return
+	}
+	for k, v := range values {
+		_ = os.Setenv(k, v)
+		fileEnv[k] = true
+	}
+}
+
+// readConfigFile reads a configuration file whose format is given by its
+// extension: .json, .yaml, .yml or .toml. Other files are read as .env files.
+// Only top-level scalar values are supported. Keys are converted to variable
+// names, so "level" and "LOG_LEVEL" both set LOG_LEVEL.
+func readConfigFile(name string) (map[string]string, error) {
+	var values map[string]string
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		values, err = readJSONConfig(name)
+	case ".yaml", ".yml":
+		values, err = readKeyValueConfig(name, ":")
+	case ".toml":
+		values, err = readKeyValueConfig(name, "=")
+	default:
+		values, err = LoadEnvFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string, len(values))
+	for k, v := range values {
+		vars[variableName(k)] = v
+	}
+	return vars, nil
+}
+
+// variableName converts a configuration key such as "show-caller" to the
+// variable name LOG_SHOW_CALLER.
+func variableName(key string) string {
+	name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(key))
+	if !strings.HasPrefix(name, "LOG_") {
+		name = "LOG_" + name
+	}
+	return name
+}
+
+func readJSONConfig(name string) (map[string]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case json.Number, bool:
+			values[k] = fmt.Sprint(v)
+		case nil:
+			values[k] = ""
+		default:
+			return nil, fmt.Errorf("%s: %s: nested values are not supported", name, k)
+		}
+	}
+	return values, nil
+}
+
+// readKeyValueConfig reads the flat subset of YAML ("key: value") or TOML
+// ("key = value") used for the logging settings.
+func readKeyValueConfig(name, sep string) (map[string]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, sep)
+		if !ok || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "-") {
+			return nil, fmt.Errorf("%s:%d: only top-level key%svalue pairs are supported", name, lineNo, sep)
+		}
+		values[strings.TrimSpace(key)] = unquoteConfigValue(value)
+	}
+	return values, scanner.Err()
+}
+
+// unquoteConfigValue strips a trailing comment and quotes from a YAML or TOML value.
+func unquoteConfigValue(value string) string {
+	value = strings.TrimSpace(value)
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+// Reload re-reads the .env files and the LOG_CONFIG file and applies the resulting
+// configuration: level, format, message policy, timezone, caller, retention,
+// directory and sinks. Records logged during the reload are written with
+// either the old or the new configuration. The changes are logged at Info
+// level. The encryption key is not reloaded. With LOG_STRICT=true an invalid
+// configuration is rejected and the current one is kept.
+func Reload() error {
+	if initPending() {
+		return Init()
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	values, sources, err := readConfigFiles()
+	if err != nil {
+		return fmt.Errorf("log: reload: %w", err)
+	}
+	configMu.RLock()
+	old := config
+	configMu.RUnlock()
+
+	// A rejected configuration leaves the file values and the environment as they were
+	cfg := loadConfigWith("LOG_", fileGetenv(values))
+	if err := cfg.Validate(); err != nil {
+		if cfg.strict {
+			return fmt.Errorf("log: reload: %w", err)
+		}
+		fprintf(os.Stderr, "%v\n", err)
+	}
+	commitConfigFiles(values, sources)
+	changes := configChanges(old, cfg)
+	if len(changes) == 0 {
+		return nil
+	}
+	applyConfig(cfg)
+	err = setupLogger()
+
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "Logging configuration reloaded", changes...)
+	if err != nil {
+		return fmt.Errorf("log: reload: %w", err)
+	}
+	return nil
+}
+
+// configChanges returns a "new (was old)" attribute for every setting that
+// differs, grouped under "changes" so that they cannot clash with record keys.
+func configChanges(old, cfg Config) []slog.Attr {
+	oldSettings, newSettings := old.settings(), cfg.settings()
+	var changes []any
+	for i, s := range newSettings {
+		if s.value != oldSettings[i].value {
+			changes = append(changes, slog.String(s.name, s.value+" (was "+oldSettings[i].value+")"))
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return []slog.Attr{slog.Group("changes", changes...)}
+}
+
+// WatchConfig reloads the configuration whenever a .env file or the LOG_CONFIG
+// file changes, checked every interval, and when the process receives SIGHUP. It
+// blocks until ctx is done. A zero interval checks every two seconds.
+// Setting LOG_WATCH=true starts it at program start.
+func WatchConfig(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := fileStates()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-ticker.C:
+			current := fileStates()
+			if current == last {
+				continue
+			}
+			last = current
+		}
+		if err := Reload(); err != nil {
+			fprintf(os.Stderr, "Failed to reload logging configuration: %v\n", err)
+		}
+	}
+}
+
+// fileStates describes the modification time and size of the configuration
+// files, to detect changes.
+func fileStates() string {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	var b strings.Builder
+	for _, name := range configFiles() {
+		if info, err := os.Stat(name); err == nil {
+			fmt.Fprintf(&b, "%s:%d:%d;", name, info.ModTime().UnixNano(), info.Size())
+		} else {
+			fmt.Fprintf(&b, "%s:-;", name)
+		}
+	}
+	return b.String()
+}
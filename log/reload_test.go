@@ -0,0 +1,273 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tsisar/extended-log-go/logcrypt"
+)
+
+// setupReload runs the test in an empty directory and restores the global
+// configuration and handlers afterwards. Reload summaries are written to the
+// returned buffer.
+func setupReload(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	origConfig, origLevel := config, logLevel.Level()
+	origHandler, origLogger, origFile, origFiles, origRing := root.handler(), logger, fileHandler, fileHandlers, ringBuffer
+	origBaseEnv, origFileEnv, origFileValues, origFileSources := baseEnv, fileEnv, fileValues, fileSources
+	baseEnv, fileEnv = environ(), make(map[string]bool)
+	fileValues, fileSources = make(map[string]string), make(map[string]string)
+
+	var buf bytes.Buffer
+	logger = slog.New(newConsoleHandler(&buf))
+
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		for k := range fileEnv {
+			_ = os.Unsetenv(k)
+		}
+		for _, h := range fileHandlers {
+			if h != origFile {
+				_ = h.Close()
+			}
+		}
+		config = origConfig
+		logLevel.Set(origLevel)
+		root.set(origHandler)
+		logger, fileHandler, fileHandlers, ringBuffer = origLogger, origFile, origFiles, origRing
+		baseEnv, fileEnv, fileValues, fileSources = origBaseEnv, origFileEnv, origFileValues, origFileSources
+	})
+	return &buf
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload_AppliesEnvFileChanges(t *testing.T) {
+	buf := setupReload(t)
+
+	writeFile(t, ".env", "LOG_LEVEL=info\nLOG_RING_BUFFER=10\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if logLevel.Level() != slog.LevelInfo || ringBuffer == nil {
+		t.Fatalf("level = %v, ring buffer = %v", logLevel.Level(), ringBuffer)
+	}
+
+	buf.Reset()
+	writeFile(t, ".env", "LOG_LEVEL=debug\nLOG_FORMAT=json\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+
+	if logLevel.Level() != slog.LevelDebug || config.format != formatJSON {
+		t.Errorf("level = %v, format = %s", logLevel.Level(), config.format)
+	}
+	if ringBuffer != nil || os.Getenv("LOG_RING_BUFFER") != "" {
+		t.Error("settings removed from .env should return to their defaults")
+	}
+	out := buf.String()
+	for _, want := range []string{"Logging configuration reloaded", `"changes.level":"debug (was info)"`, `"changes.format":"json (was text)"`, `"changes.ring_buffer":"0 (was 10)"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("summary should contain %s, got %q", want, out)
+		}
+	}
+}
+
+func TestReload_ProcessEnvironmentWins(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "error")
+	setupReload(t)
+
+	writeFile(t, ".env", "LOG_LEVEL=debug\nLOG_SHOW_CALLER=true\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if logLevel.Level() != slog.LevelError || !config.showCaller {
+		t.Errorf("level = %v, showCaller = %v", logLevel.Level(), config.showCaller)
+	}
+}
+
+func TestReload_ConfigFile(t *testing.T) {
+	setupReload(t)
+
+	writeFile(t, "logging.yaml", "# logging\nlevel: debug\nformat: \"logfmt\"\nretention-days: 7 # one week\n")
+	writeFile(t, ".env", "LOG_CONFIG=logging.yaml\nLOG_LEVEL=info\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if logLevel.Level() != slog.LevelDebug || config.format != formatLogfmt || config.retention != 7*day {
+		t.Errorf("level = %v, format = %s, retention = %v", logLevel.Level(), config.format, config.retention)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.json": `{"level": "warn", "LOG_SHOW_CALLER": true, "retention_days": 14}`,
+		"config.toml": "# logging\nlevel = \"warn\"\nshow_caller = true\nretention_days = 14\n",
+		"config.yml":  "level: warn\nshow_caller: true\nretention_days: '14'\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		writeFile(t, path, content)
+		values, err := readConfigFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if values["LOG_LEVEL"] != "warn" || values["LOG_SHOW_CALLER"] != "true" || values["LOG_RETENTION_DAYS"] != "14" {
+			t.Errorf("%s: got %v", name, values)
+		}
+	}
+
+	nested := filepath.Join(dir, "nested.toml")
+	writeFile(t, nested, "[log]\nlevel = \"warn\"\n")
+	if _, err := readConfigFile(nested); err == nil {
+		t.Error("tables should be rejected")
+	}
+}
+
+func TestFileHandler_RetirePassesRecordsOn(t *testing.T) {
+	withFormat(t, formatText, false)
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	oldHandler, newHandler := newFileHandler(oldDir), newFileHandler(newDir)
+	defer func() { _ = newHandler.Close() }()
+
+	oldHandler.retire(newHandler)
+	_ = oldHandler.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "in flight", 0))
+
+	name := time.Now().In(config.loc()).Format("2006-01-02") + ".log"
+	data, err := os.ReadFile(filepath.Join(newDir, name))
+	if err != nil || !strings.Contains(string(data), "in flight") {
+		t.Errorf("record should be written by the successor, got %q, %v", data, err)
+	}
+}
+
+func TestReload_LayeredEnvFiles(t *testing.T) {
+	t.Setenv("APP_ENV", "staging")
+	setupReload(t)
+
+	writeFile(t, ".env", "LOG_LEVEL=info\nLOG_FORMAT=json\nLOG_DIRECTORY=data/logs\n")
+	writeFile(t, ".env.staging", "LOG_LEVEL=warn\nLOG_FORMAT=text\nLOG_DIRECTORY=${LOG_DIRECTORY}/staging\n")
+	writeFile(t, ".env.local", "LOG_LEVEL=debug\nLOG_FORMAT=logfmt\n")
+	writeFile(t, ".env.staging.local", "LOG_LEVEL=error\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	// .env.local overrides .env.staging, and .env.staging.local overrides both
+	if logLevel.Level() != slog.LevelError || config.format != formatLogfmt || config.directory != "data/logs/staging" {
+		t.Errorf("level = %v, format = %s, directory = %s", logLevel.Level(), config.format, config.directory)
+	}
+}
+
+func TestReload_EnvFileVariable(t *testing.T) {
+	t.Setenv("LOG_ENV_FILE", "base.env, override.env")
+	setupReload(t)
+
+	writeFile(t, ".env", "LOG_LEVEL=error\n")
+	writeFile(t, "base.env", "LOG_LEVEL=info\nLOG_SHOW_CALLER=true\n")
+	writeFile(t, "override.env", "LOG_LEVEL=warn\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if logLevel.Level() != slog.LevelWarn || !config.showCaller {
+		t.Errorf("level = %v, showCaller = %v", logLevel.Level(), config.showCaller)
+	}
+}
+
+func TestReload_EnvFilesDisabled(t *testing.T) {
+	t.Setenv("LOG_ENV_FILE", "none")
+	t.Setenv("LOG_CONFIG", "logging.json")
+	setupReload(t)
+
+	writeFile(t, "logging.json", `{"level": "warn"}`)
+	writeFile(t, ".env", "LOG_SHOW_CALLER=true\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if config.showCaller {
+		t.Error(".env should not be read")
+	}
+	if logLevel.Level() != slog.LevelWarn {
+		t.Errorf("level = %v, want the LOG_CONFIG value", logLevel.Level())
+	}
+	if _, ok := os.LookupEnv("LOG_LEVEL"); ok {
+		t.Error("the environment should not be modified")
+	}
+}
+
+func TestReload_EmptyVariableWins(t *testing.T) {
+	t.Setenv("LOG_SHOW_CALLER", "")
+	setupReload(t)
+
+	writeFile(t, ".env", "LOG_SHOW_CALLER=true\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if config.showCaller || os.Getenv("LOG_SHOW_CALLER") != "" {
+		t.Error("an empty variable in the environment should not be overwritten")
+	}
+}
+
+func TestReload_ConcurrentReaders(t *testing.T) {
+	setupReload(t)
+	h := newFileHandler(t.TempDir())
+	defer func() { _ = h.Close() }()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 20 {
+			_ = os.WriteFile(".env", []byte("LOG_LEVEL=debug\nLOG_APP_NAME=app"+strconv.Itoa(i)+"\n"), 0o600)
+			_ = Reload()
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		_ = ConfigFromEnvPrefix("LOG_")
+		_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "during reload", 0))
+	}
+}
+
+func TestSetKeyProvider_ConcurrentReload(t *testing.T) {
+	setupReload(t)
+	writeFile(t, ".env", "LOG_SAVE=true\nLOG_DIRECTORY="+t.TempDir()+"\n")
+	key := make([]byte, 32)
+	defer func() { _ = SetKeyProvider(nil) }()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 10 {
+			_ = SetKeyProvider(logcrypt.KeyFunc(func() ([]byte, error) { return key, nil }))
+		}
+	}()
+	for i := range 10 {
+		writeFile(t, ".env", "LOG_SAVE=true\nLOG_DIRECTORY="+t.TempDir()+"\nLOG_LEVEL="+[]string{"info", "debug"}[i%2]+"\n")
+		_ = Reload()
+	}
+	<-done
+}
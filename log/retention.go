@@ -0,0 +1,293 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+	"github.com/tsisar/extended-log-go/logcrypt"
+)
+
+// Reasons for removing a log file, passed to the OnRemove hook.
+const (
+	RemovedByAge       = "age"
+	RemovedByFileCount = "max_files"
+	RemovedByTotalSize = "max_total_size"
+)
+
+// RemovedFile describes a log file deleted by the retention policies.
+type RemovedFile struct {
+	Path   string
+	Size   int64
+	Time   time.Time // the start of the period the file was for
+	Reason string    // RemovedByAge, RemovedByFileCount or RemovedByTotalSize
+}
+
+var (
+	removeHook   func(RemovedFile)
+	removeHookMu sync.Mutex
+)
+
+// OnRemove registers fn to be called for every log file removed by the
+// retention policies. fn is called from the goroutine cleaning up old files
+// and may log through this package. nil removes the hook.
+func OnRemove(fn func(RemovedFile)) {
+	removeHookMu.Lock()
+	defer removeHookMu.Unlock()
+	removeHook = fn
+}
+
+func notifyRemoved(f RemovedFile) {
+	removeHookMu.Lock()
+	hook := removeHook
+	removeHookMu.Unlock()
+	if hook != nil {
+		hook(f)
+	}
+}
+
+// cleanupLockName is the file in the log directory locked by the process
+// cleaning up old files when several processes share the directory.
+const cleanupLockName = ".cleanup.lock"
+
+// retentionPolicy holds the settings of a cleanup, taken from the
+// configuration when a log file is opened.
+type retentionPolicy struct {
+	basePath     string
+	templates    []*filename.Template
+	loc          *time.Location
+	maxAge       time.Duration
+	maxTotalSize int64
+	maxFiles     int
+	current      string    // the open file, never removed
+	since        time.Time // files of this period or later are never removed
+	archiver     Archiver
+	lock         bool // other processes clean up the same directory
+}
+
+func (p *retentionPolicy) enabled() bool {
+	return p.maxAge > 0 || p.maxTotalSize > 0 || p.maxFiles > 0 || p.archiver != nil
+}
+
+// retentionPolicy returns the cleanup for the current configuration and file.
+func (h *FileHandler) retentionPolicy() *retentionPolicy {
+	loc := h.conf.loc()
+	templates := h.retained
+	if templates == nil {
+		templates = []*filename.Template{h.template()}
+	}
+	return &retentionPolicy{
+		basePath:     h.basePath,
+		templates:    templates,
+		loc:          loc,
+		maxAge:       h.conf.retention,
+		maxTotalSize: h.conf.maxTotalSize,
+		maxFiles:     h.conf.maxFiles,
+		current:      h.path,
+		since:        periodStart(time.Now().In(loc), h.conf.rotation),
+		archiver:     currentArchiver(),
+		lock:         h.conf.processMode == processLock || h.conf.processMode == processPID,
+	}
+}
+
+// startCleanup archives and removes old log files in the background. A
+// cleanup requested while one is running is run after it.
+func (h *FileHandler) startCleanup() {
+	p := h.retentionPolicy()
+	if !p.enabled() {
+		return
+	}
+	h.cleanup = p
+	if h.cleanDone != nil {
+		return
+	}
+	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cleanDone, h.cleanStop = done, cancel
+	go func() {
+		defer close(done)
+		defer cancel()
+		for {
+			h.mu.Lock()
+			p := h.cleanup
+			h.cleanup = nil
+			if p == nil {
+				h.cleanDone = nil
+				h.mu.Unlock()
+				return
+			}
+			h.mu.Unlock()
+			p.run(ctx)
+		}
+	}()
+}
+
+// cleanOldLogs archives and removes old log files synchronously.
+func (h *FileHandler) cleanOldLogs() {
+	if p := h.retentionPolicy(); p.enabled() {
+		p.run(context.Background())
+	}
+}
+
+// logFile is a file in the log directory matching the file name template.
+type logFile struct {
+	path string
+	name string // relative to the log directory with / separators
+	time time.Time
+	size int64
+}
+
+func (f logFile) archiveFile() ArchiveFile {
+	return ArchiveFile{Path: f.path, Name: f.name, Time: f.time, Size: f.size}
+}
+
+// keep reports whether f may still be written to.
+func (p *retentionPolicy) keep(f logFile) bool {
+	return f.path == p.current || !f.time.Before(p.since)
+}
+
+// run archives the log files of past periods that are not archived yet. Then
+// it removes the log files matching the file name template, oldest first,
+// that are older than the retention period or exceed the limits on the
+// number of files and their total size, and the directories emptied by
+// removing them. With an archiver, only archived files are removed.
+func (p *retentionPolicy) run(ctx context.Context) {
+	if p.lock {
+		// Skip the cleanup while another process is running it
+		f, err := os.OpenFile(filepath.Join(p.basePath, cleanupLockName), os.O_CREATE|os.O_RDWR, defaultFileMode)
+		if err != nil {
+			diag.report(SinkFile, "lock", err)
+			return
+		}
+		defer f.Close()
+		if ok, err := tryLockFile(f); !ok {
+			if err != nil {
+				diag.report(SinkFile, "lock", err)
+			}
+			return
+		}
+		defer unlockFile(f)
+	}
+
+	files := p.list()
+	manifest := p.archive(ctx, files)
+	total := int64(0)
+	for _, f := range files {
+		total += f.size
+	}
+	count := len(files)
+	cutoff := time.Now().In(p.loc).Add(-p.maxAge)
+
+	for _, f := range files {
+		var reason string
+		switch {
+		case p.keep(f), p.archiver != nil && !archived(manifest, f.archiveFile()):
+			continue
+		case p.maxAge > 0 && f.time.Before(cutoff):
+			reason = RemovedByAge
+		case p.maxFiles > 0 && count > p.maxFiles:
+			reason = RemovedByFileCount
+		case p.maxTotalSize > 0 && total > p.maxTotalSize:
+			reason = RemovedByTotalSize
+		default:
+			continue
+		}
+
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			metrics.WriteError(SinkFile, err)
+			diag.report(SinkFile, "remove", err)
+			continue
+		}
+		count--
+		total -= f.size
+		p.removeEmptyDirs(filepath.Dir(f.path))
+		notifyRemoved(RemovedFile{Path: f.path, Size: f.size, Time: f.time, Reason: reason})
+	}
+}
+
+// archive archives the files of past periods missing from the manifest and
+// returns the manifest.
+func (p *retentionPolicy) archive(ctx context.Context, files []logFile) map[string]manifestEntry {
+	if p.archiver == nil {
+		return nil
+	}
+	manifest := readManifest(p.basePath)
+	for _, f := range files {
+		if p.keep(f) || archived(manifest, f.archiveFile()) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		e, err := p.archiveFile(ctx, f.archiveFile())
+		if errors.Is(err, context.Canceled) {
+			break
+		}
+		if err != nil {
+			metrics.WriteError(SinkFile, err)
+			diag.report(SinkFile, "archive", err)
+			continue
+		}
+		manifest[e.File] = e
+	}
+	return manifest
+}
+
+// list returns the log files in the directory, oldest first.
+func (p *retentionPolicy) list() []logFile {
+	var files []logFile
+	_ = filepath.WalkDir(p.basePath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(p.basePath, filePath)
+		if err != nil {
+			return nil
+		}
+
+		// Extract the date from the file name, encrypted or not
+		fileDate, ok := p.match(strings.TrimSuffix(rel, logcrypt.Extension))
+		if !ok {
+			return nil // Skip files that don't match a template
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, logFile{path: filePath, name: filepath.ToSlash(rel), time: fileDate, size: info.Size()})
+		return nil
+	})
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].time.Before(files[j].time)
+	})
+	return files
+}
+
+// match returns the time of a file named by one of the templates.
+func (p *retentionPolicy) match(rel string) (time.Time, bool) {
+	for _, t := range p.templates {
+		if date, ok := t.Match(rel, p.loc); ok {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// removeEmptyDirs removes dir and its parents up to the log directory as long
+// as they are empty.
+func (p *retentionPolicy) removeEmptyDirs(dir string) {
+	base := filepath.Clean(p.basePath)
+	for dir != base && strings.HasPrefix(dir, base+string(filepath.Separator)) {
+		if os.Remove(dir) != nil {
+			return // not empty
+		}
+		dir = filepath.Dir(dir)
+	}
+}
@@ -0,0 +1,141 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeLogFiles creates a 100 byte log file for each of the last n days,
+// oldest first, and returns their paths.
+func writeLogFiles(t *testing.T, dir string, n int) []string {
+	t.Helper()
+	var paths []string
+	for i := n - 1; i >= 0; i-- {
+		path := filepath.Join(dir, time.Now().AddDate(0, 0, -i).Format("2006-01-02")+".log")
+		if err := os.WriteFile(path, []byte(strings.Repeat("x", 99)+"\n"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func recordRemovals(t *testing.T) func() []RemovedFile {
+	var mu sync.Mutex
+	var removed []RemovedFile
+	OnRemove(func(f RemovedFile) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, f)
+	})
+	t.Cleanup(func() { OnRemove(nil) })
+	return func() []RemovedFile {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(removed)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestRetention_MaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeLogFiles(t, dir, 5)
+	removed := recordRemovals(t)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxFiles = 0, 2
+	h := &FileHandler{basePath: dir, conf: conf}
+	h.cleanOldLogs()
+
+	for i, path := range paths {
+		if want := i >= 3; exists(path) != want {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(path), !want, want)
+		}
+	}
+	got := removed()
+	if len(got) != 3 || got[0].Path != paths[0] || got[0].Reason != RemovedByFileCount || got[0].Size != 100 {
+		t.Errorf("removed = %+v", got)
+	}
+}
+
+func TestRetention_MaxTotalSize(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeLogFiles(t, dir, 4)
+	removed := recordRemovals(t)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxTotalSize = 0, 250
+	h := &FileHandler{basePath: dir, conf: conf}
+	h.cleanOldLogs()
+
+	if exists(paths[0]) || exists(paths[1]) || !exists(paths[2]) || !exists(paths[3]) {
+		t.Error("the two oldest files should be removed to get below 250 bytes")
+	}
+	if got := removed(); len(got) != 2 || got[1].Reason != RemovedByTotalSize {
+		t.Errorf("removed = %+v", got)
+	}
+}
+
+func TestRetention_KeepsCurrentFile(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeLogFiles(t, dir, 3)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxTotalSize, conf.maxFiles = 0, 1, 0
+	h := &FileHandler{basePath: dir, conf: conf, path: paths[0]}
+	h.cleanOldLogs()
+
+	if !exists(paths[0]) || exists(paths[1]) || !exists(paths[2]) {
+		t.Error("only the file that is neither open nor of the current day should be removed")
+	}
+}
+
+func TestRetention_Background(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeLogFiles(t, dir, 3)
+	removed := recordRemovals(t)
+
+	conf := fileConfig(t, "{date}.log")
+	conf.retention, conf.maxFiles = 0, 1
+	h := newConfiguredFileHandler(conf, LevelTrace, dir, nil)
+	if err := h.Close(); err != nil { // waits for the cleanup
+		t.Fatal(err)
+	}
+
+	if exists(paths[0]) || exists(paths[1]) || !exists(paths[2]) {
+		t.Error("all but the current file should be removed")
+	}
+	if got := removed(); len(got) != 2 {
+		t.Errorf("removed = %+v", got)
+	}
+}
+
+func TestLoadConfig_RetentionLimits(t *testing.T) {
+	t.Setenv("LOG_MAX_TOTAL_SIZE", "1.5GB")
+	t.Setenv("LOG_MAX_FILES", "20")
+	setupReload(t)
+
+	cfg := loadConfig("LOG_")
+	if cfg.maxTotalSize != 1.5e9 || cfg.maxFiles != 20 || cfg.Validate() != nil {
+		t.Errorf("max total size = %d, max files = %d, errors = %v", cfg.maxTotalSize, cfg.maxFiles, cfg.Validate())
+	}
+
+	t.Setenv("LOG_MAX_TOTAL_SIZE", "lots")
+	t.Setenv("LOG_MAX_FILES", "-1")
+	cfg = loadConfig("LOG_")
+	if cfg.maxTotalSize != 0 || cfg.maxFiles != 0 {
+		t.Errorf("max total size = %d, max files = %d", cfg.maxTotalSize, cfg.maxFiles)
+	}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "LOG_MAX_TOTAL_SIZE") || !strings.Contains(err.Error(), "LOG_MAX_FILES") {
+		t.Errorf("Validate() = %v", err)
+	}
+}
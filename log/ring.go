@@ -0,0 +1,204 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrRingBufferDisabled is returned by DumpRecent when LOG_RING_BUFFER is not set.
+var ErrRingBufferDisabled = errors.New("log: ring buffer is disabled")
+
+// RingBufferHandler is a slog handler that keeps the most recent records in
+// memory regardless of the log level. In flight recorder mode, an Error record
+// makes it write the buffered records that the file sink skipped because of
+// its level, so the file shows what led up to the error.
+type RingBufferHandler struct {
+	records []slog.Record
+	next    int
+	full    bool
+	flushTo slog.Handler
+	mu      sync.Mutex
+}
+
+func newRingBufferHandler(size int) *RingBufferHandler {
+	return &RingBufferHandler{
+		records: make([]slog.Record, size),
+	}
+}
+
+func (h *RingBufferHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+func (h *RingBufferHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records[h.next] = r.Clone()
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+
+	if h.flushTo != nil && r.Level >= slog.LevelError {
+		return h.flush(ctx)
+	}
+	return nil
+}
+
+func (h *RingBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
+}
+
+func (h *RingBufferHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
+}
+
+// setFlushTo sets the sink written to when an Error record arrives (nil disables flushing).
+func (h *RingBufferHandler) setFlushTo(sink slog.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flushTo = sink
+}
+
+// flush writes the buffered records below the sink's level, excluding the
+// error record itself, and empties the buffer so nothing is written twice.
+func (h *RingBufferHandler) flush(ctx context.Context) error {
+	records := h.snapshot()
+	h.next, h.full = 0, false
+	clear(h.records)
+
+	var firstErr error
+	for _, r := range records[:len(records)-1] {
+		if h.flushTo.Enabled(ctx, r.Level) {
+			continue // already written by the sink itself
+		}
+		if err := h.flushTo.Handle(ctx, r); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// snapshot returns the buffered records from oldest to newest.
+func (h *RingBufferHandler) snapshot() []slog.Record {
+	var records []slog.Record
+	if h.full {
+		records = append(records, h.records[h.next:]...)
+	}
+	return append(records, h.records[:h.next]...)
+}
+
+// Recent returns the buffered records at or above minLevel whose message or
+// attributes contain text, oldest first.
+func (h *RingBufferHandler) Recent(minLevel slog.Level, text string) []slog.Record {
+	h.mu.Lock()
+	records := h.snapshot()
+	h.mu.Unlock()
+
+	configMu.RLock()
+	loc := config.loc()
+	configMu.RUnlock()
+
+	filtered := records[:0]
+	for _, r := range records {
+		if r.Level >= minLevel && recordContains(r, text, loc) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// recordContains reports whether the message or an attribute of r contains
+// text, with time values formatted in loc.
+func recordContains(r slog.Record, text string, loc *time.Location) bool {
+	if text == "" || strings.Contains(r.Message, text) {
+		return true
+	}
+	for _, a := range recordAttrs(r) {
+		if strings.Contains(a.Key, text) || strings.Contains(attrString(a.Value, loc), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// DumpRecent writes all records kept by the ring buffer to w in the
+// configured output format.
+func DumpRecent(w io.Writer) error {
+	ring := currentRingBuffer()
+	if ring == nil {
+		return ErrRingBufferDisabled
+	}
+	return writeRecords(w, ring.Recent(LevelTrace, ""))
+}
+
+// currentRingBuffer returns the ring buffer of the package-level logger, or
+// nil if LOG_RING_BUFFER is not set.
+func currentRingBuffer() *RingBufferHandler {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return ringBuffer
+}
+
+func writeRecords(w io.Writer, records []slog.Record) error {
+	for _, r := range records {
+		if _, err := w.Write(formatRecord(&config, r, false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecentHandler returns an http.Handler serving the records kept by the ring
+// buffer. The query parameters level (minimum level, e.g. warn), q (substring
+// of the message or attributes) and limit (newest N records) filter the output.
+func RecentHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ring := currentRingBuffer()
+		if ring == nil {
+			http.Error(w, ErrRingBufferDisabled.Error(), http.StatusNotFound)
+			return
+		}
+
+		query := req.URL.Query()
+		minLevel := LevelTrace
+		if s := query.Get("level"); s != "" {
+			level, ok := parseLevel(s)
+			if !ok {
+				http.Error(w, "invalid level", http.StatusBadRequest)
+				return
+			}
+			minLevel = level
+		}
+
+		records := ring.Recent(minLevel, query.Get("q"))
+		if s := query.Get("limit"); s != "" {
+			limit, err := strconv.Atoi(s)
+			if err != nil || limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			if limit < len(records) {
+				records = records[len(records)-limit:]
+			}
+		}
+
+		configMu.RLock()
+		format := config.format
+		configMu.RUnlock()
+		if format == formatJSON {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		_ = writeRecords(w, records)
+	})
+}
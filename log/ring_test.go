@@ -0,0 +1,185 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupRingBuffer installs a ring buffer of the given size as the global
+// logger's only handler and returns a cleanup function.
+func setupRingBuffer(size int) func() {
+	origLogger, origRing, origLevel := logger, ringBuffer, logLevel.Level()
+	ringBuffer = newRingBufferHandler(size)
+	logger = slog.New(ringBuffer)
+	return func() {
+		logger, ringBuffer = origLogger, origRing
+		logLevel.Set(origLevel)
+	}
+}
+
+func TestRingBufferHandler_KeepsMostRecent(t *testing.T) {
+	h := newRingBufferHandler(3)
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), LevelTrace, msg, 0))
+	}
+
+	records := h.Recent(LevelTrace, "")
+	var messages []string
+	for _, r := range records {
+		messages = append(messages, r.Message)
+	}
+	if strings.Join(messages, ",") != "two,three,four" {
+		t.Errorf("messages = %v, want [two three four]", messages)
+	}
+}
+
+func TestRingBufferHandler_IgnoresLevel(t *testing.T) {
+	cleanup := setupRingBuffer(10)
+	defer cleanup()
+	logLevel.Set(slog.LevelError)
+
+	var console bytes.Buffer
+	logger = slog.New(newMultiHandler(newConsoleHandler(&console), ringBuffer))
+
+	Trace("trace detail")
+	Error("failure")
+
+	if strings.Contains(console.String(), "trace detail") {
+		t.Error("console should not contain trace record")
+	}
+	var dump bytes.Buffer
+	if err := DumpRecent(&dump); err != nil {
+		t.Fatalf("DumpRecent() error: %v", err)
+	}
+	if !strings.Contains(dump.String(), "trace detail") || !strings.Contains(dump.String(), "failure") {
+		t.Errorf("dump should contain all records, got %q", dump.String())
+	}
+}
+
+func TestRingBufferHandler_FlightRecorder(t *testing.T) {
+	withFormat(t, formatText, false)
+	origLevel := logLevel.Level()
+	defer logLevel.Set(origLevel)
+	logLevel.Set(slog.LevelInfo)
+
+	dir := t.TempDir()
+	fh := newFileHandler(dir)
+	defer func() { _ = fh.Close() }()
+	ring := newRingBufferHandler(10)
+	ring.setFlushTo(fh)
+	h := newMultiHandler(ring, fh)
+
+	for _, r := range []slog.Record{
+		slog.NewRecord(time.Now(), slog.LevelDebug, "debug before error", 0),
+		slog.NewRecord(time.Now(), slog.LevelInfo, "info before error", 0),
+		slog.NewRecord(time.Now(), slog.LevelError, "the error", 0),
+		slog.NewRecord(time.Now(), slog.LevelDebug, "debug after error", 0),
+	} {
+		if err := h.Handle(context.Background(), r); err != nil {
+			t.Fatalf("Handle() error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, time.Now().In(config.loc()).Format("2006-01-02")+".log"))
+	if err != nil {
+		t.Fatalf("could not read log file: %v", err)
+	}
+	content := string(data)
+	if strings.Count(content, "info before error") != 1 {
+		t.Errorf("info record should be written exactly once, got:\n%s", content)
+	}
+	debugAt := strings.Index(content, "debug before error")
+	errorAt := strings.Index(content, "the error")
+	if debugAt < 0 || debugAt > errorAt {
+		t.Errorf("debug record should be flushed before the error, got:\n%s", content)
+	}
+	if strings.Contains(content, "debug after error") {
+		t.Errorf("records after the error should stay in memory, got:\n%s", content)
+	}
+}
+
+func TestRecentHandler_Filters(t *testing.T) {
+	withFormat(t, formatText, false)
+	cleanup := setupRingBuffer(10)
+	defer cleanup()
+
+	ctx := context.Background()
+	_ = ringBuffer.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelDebug, "cache miss", 0))
+	_ = ringBuffer.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelWarn, "slow query", 0))
+	r := slog.NewRecord(time.Now(), slog.LevelError, "query failed", 0)
+	r.AddAttrs(slog.Any("err", errors.New("timeout")))
+	_ = ringBuffer.Handle(ctx, r)
+
+	tests := []struct {
+		query    string
+		contains []string
+		excludes []string
+	}{
+		{"", []string{"cache miss", "slow query", "query failed"}, nil},
+		{"?level=warn", []string{"slow query", "query failed"}, []string{"cache miss"}},
+		{"?q=timeout", []string{"query failed"}, []string{"slow query"}},
+		{"?limit=1", []string{"query failed"}, []string{"slow query"}},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		RecentHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs"+tt.query, nil))
+		body := rec.Body.String()
+		for _, s := range tt.contains {
+			if !strings.Contains(body, s) {
+				t.Errorf("%q: expected %q in response, got %q", tt.query, s, body)
+			}
+		}
+		for _, s := range tt.excludes {
+			if strings.Contains(body, s) {
+				t.Errorf("%q: did not expect %q in response, got %q", tt.query, s, body)
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	RecentHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs?level=loud", nil))
+	if rec.Code != 400 {
+		t.Errorf("invalid level: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestDumpRecent_Disabled(t *testing.T) {
+	orig := ringBuffer
+	ringBuffer = nil
+	defer func() { ringBuffer = orig }()
+
+	if err := DumpRecent(&bytes.Buffer{}); !errors.Is(err, ErrRingBufferDisabled) {
+		t.Errorf("DumpRecent() = %v, want ErrRingBufferDisabled", err)
+	}
+}
+
+func TestDumpRecent_ConcurrentReload(t *testing.T) {
+	setupReload(t)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := range 20 {
+			_ = os.WriteFile(".env", []byte("LOG_RING_BUFFER="+[]string{"0", "10", "20"}[i%3]+"\n"), 0o600)
+			_ = Reload()
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		_ = DumpRecent(io.Discard)
+		RecentHandler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/recent", nil))
+	}
+}
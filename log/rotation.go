@@ -0,0 +1,44 @@
+package log
+
+import (
+	"time"
+)
+
+// Rotation intervals with their own names in LOG_ROTATION.
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// periodStart returns the start of the rotation period containing now. Days
+// and weeks (starting on Monday) follow the calendar of now's location;
+// shorter intervals are counted from midnight, so every day starts a period.
+func periodStart(now time.Time, interval time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch {
+	case interval <= 0 || interval == day:
+		return midnight
+	case interval == week:
+		return midnight.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+	}
+	return midnight.Add(now.Sub(midnight) / interval * interval)
+}
+
+// nextPeriod returns the start of the rotation period after the one starting at start.
+func nextPeriod(start time.Time, interval time.Duration) time.Time {
+	switch {
+	case interval <= 0 || interval == day:
+		return start.AddDate(0, 0, 1)
+	case interval == week:
+		return start.AddDate(0, 0, 7)
+	}
+	midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location())
+	return minTime(start.Add(interval), midnight.AddDate(0, 0, 1))
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
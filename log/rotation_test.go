@@ -0,0 +1,98 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 10, 15, 23, 50, 10, 0, time.UTC) // a Thursday
+	tests := []struct {
+		interval    time.Duration
+		start, next time.Time
+	}{
+		{day, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{time.Hour, time.Date(2026, 10, 15, 23, 0, 0, 0, time.UTC), time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{15 * time.Minute, time.Date(2026, 10, 15, 23, 45, 0, 0, time.UTC), time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{7 * time.Hour, time.Date(2026, 10, 15, 21, 0, 0, 0, time.UTC), time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{week, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		start := periodStart(now, tt.interval)
+		if !start.Equal(tt.start) {
+			t.Errorf("periodStart(%v) = %v, want %v", tt.interval, start, tt.start)
+		}
+		if next := nextPeriod(start, tt.interval); !next.Equal(tt.next) {
+			t.Errorf("nextPeriod(%v) = %v, want %v", tt.interval, next, tt.next)
+		}
+	}
+}
+
+func TestLoadConfig_Rotation(t *testing.T) {
+	t.Setenv("LOG_ROTATION", "hourly")
+	t.Setenv("LOG_FILE_NAME", "")
+	t.Setenv("LOG_RETENTION", "72h")
+	setupReload(t)
+
+	cfg := loadConfig("LOG_")
+	if cfg.rotation != time.Hour || cfg.fileName != "{time:2006-01-02T15}.log" || cfg.retention != 72*time.Hour {
+		t.Errorf("rotation = %v, file name = %s, retention = %v", cfg.rotation, cfg.fileName, cfg.retention)
+	}
+
+	// A file name that stays the same for a whole day cannot rotate hourly
+	t.Setenv("LOG_FILE_NAME", "{date}.log")
+	cfg = loadConfig("LOG_")
+	if cfg.fileName != "{time:2006-01-02T15}.log" || cfg.Validate() == nil {
+		t.Errorf("file name = %s, Validate() = %v", cfg.fileName, cfg.Validate())
+	}
+
+	t.Setenv("LOG_ROTATION", "")
+	t.Setenv("LOG_RETENTION", "")
+	t.Setenv("LOG_RETENTION_DAYS", "3")
+	cfg = loadConfig("LOG_")
+	if cfg.rotation != day || cfg.retention != 3*day {
+		t.Errorf("rotation = %v, retention = %v", cfg.rotation, cfg.retention)
+	}
+}
+
+func TestFileHandler_TimerRotation(t *testing.T) {
+	dir := t.TempDir()
+	conf := fileConfig(t, "{time:2006-01-02T15-04}.log")
+	conf.rotation = time.Minute
+	h := &FileHandler{basePath: dir, conf: conf, level: slog.LevelInfo}
+	defer h.Close()
+
+	h.mu.Lock()
+	h.ensureLogFile()
+	timer := h.timer
+	h.mu.Unlock()
+	if timer == nil {
+		t.Fatal("the rotation timer should be running")
+	}
+
+	// Pretend the period ended a minute ago and fire the timer
+	h.mu.Lock()
+	h.rotateAt = h.rotateAt.Add(-2 * time.Minute)
+	h.path = filepath.Join(dir, "old.log")
+	h.mu.Unlock()
+	h.rotate()
+
+	h.mu.Lock()
+	second := h.path
+	h.mu.Unlock()
+	if filepath.Base(second) == "old.log" {
+		t.Error("rotate() should switch to the file of the current period")
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "after rotation", 0)
+	if err := h.Handle(context.Background(), r); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if _, err := os.Stat(second); err != nil {
+		t.Errorf("log file should exist: %v", err)
+	}
+}
@@ -0,0 +1,188 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Route sends the records matching all of its conditions to the named sinks
+// of a RouterHandler. A route without conditions matches every record.
+type Route struct {
+	// Levels are the levels matched, e.g. "warn+", "debug-info" or "error".
+	Levels string
+	// Message matches the record message.
+	Message *regexp.Regexp
+	// Attr is the key of an attribute the record must have, qualified by its
+	// groups, e.g. "http.method". Value is its value, any value if empty.
+	Attr  string
+	Value string
+	// Component is the value of the record's "component" attribute.
+	Component string
+	// Package is the import path of the package logging the record, taken
+	// from its caller. It requires a logger that records the caller PC.
+	Package string
+	// Sinks are the names of the sinks the record is sent to.
+	Sinks []string
+	// Stop ends the routing of a matching record; later routes are skipped.
+	Stop bool
+}
+
+// route is a Route with its level range parsed.
+type route struct {
+	Route
+	levels *levelRange
+}
+
+func (rt *route) matches(r slog.Record, attrs []slog.Attr) bool {
+	if rt.levels != nil && !rt.levels.contains(r.Level) {
+		return false
+	}
+	if rt.Message != nil && !rt.Message.MatchString(r.Message) {
+		return false
+	}
+	if rt.Attr != "" && !hasAttr(attrs, rt.Attr, rt.Value) {
+		return false
+	}
+	if rt.Component != "" && !hasAttr(attrs, "component", rt.Component) {
+		return false
+	}
+	if rt.Package != "" && callerPackage(r) != rt.Package {
+		return false
+	}
+	return true
+}
+
+// hasAttr reports whether attrs contain key with value, or with any value if
+// value is empty.
+func hasAttr(attrs []slog.Attr, key, value string) bool {
+	for _, a := range attrs {
+		if a.Key == key && (value == "" || a.Value.String() == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// callerPackage returns the import path of the function at the record's PC.
+func callerPackage(r slog.Record) string {
+	if r.PC == 0 {
+		return ""
+	}
+	fs := runtime.CallersFrames([]uintptr{r.PC})
+	f, _ := fs.Next()
+	return funcPackage(f.Function)
+}
+
+// funcPackage returns the import path of a function name reported by the
+// runtime: path/to/pkg.Func or path/to/pkg.(*Type).Method. Dots in the last
+// path element are escaped like other special characters, gopkg.in/yaml.v3
+// appears as gopkg.in/yaml%2ev3, so the first dot after the last slash ends
+// the import path.
+func funcPackage(name string) string {
+	dir := ""
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		dir, name = name[:i+1], name[i+1:]
+	}
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	path, err := url.PathUnescape(dir + name)
+	if err != nil {
+		return dir + name
+	}
+	return path
+}
+
+// RouterHandler sends every record to the sinks of the routes it matches,
+// evaluated in order. Unlike MultiHandler, a record only reaches the sinks
+// chosen for it, and records matching no route are discarded.
+type RouterHandler struct {
+	sinks  map[string]slog.Handler
+	routes []route
+}
+
+// NewRouterHandler returns a handler routing records to the named sinks, e.g.
+// audit events to an audit file and everything else to the default handler:
+//
+//	NewRouterHandler(map[string]slog.Handler{"audit": auditFile, "default": h},
+//		Route{Component: "audit", Sinks: []string{"audit"}, Stop: true},
+//		Route{Sinks: []string{"default"}})
+func NewRouterHandler(sinks map[string]slog.Handler, routes ...Route) (*RouterHandler, error) {
+	h := &RouterHandler{sinks: sinks, routes: make([]route, len(routes))}
+	for i, r := range routes {
+		if len(r.Sinks) == 0 {
+			return nil, fmt.Errorf("log: route %d has no sinks", i)
+		}
+		for _, name := range r.Sinks {
+			if sinks[name] == nil {
+				return nil, fmt.Errorf("log: route %d: unknown sink %q", i, name)
+			}
+		}
+		h.routes[i].Route = r
+		if r.Levels != "" {
+			levels, err := parseLevelRange(r.Levels)
+			if err != nil {
+				return nil, fmt.Errorf("log: route %d: %w", i, err)
+			}
+			h.routes[i].levels = &levels
+		}
+	}
+	return h, nil
+}
+
+func (h *RouterHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, rt := range h.routes {
+		if rt.levels != nil && !rt.levels.contains(level) {
+			continue
+		}
+		for _, name := range rt.Sinks {
+			if h.sinks[name].Enabled(ctx, level) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (h *RouterHandler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := recordAttrs(r)
+	var sent []string
+	var firstErr error
+	for i := range h.routes {
+		rt := &h.routes[i]
+		if !rt.matches(r, attrs) {
+			continue
+		}
+		for _, name := range rt.Sinks {
+			if slices.Contains(sent, name) {
+				continue
+			}
+			sent = append(sent, name)
+			sink := h.sinks[name]
+			if !sink.Enabled(ctx, r.Level) {
+				continue
+			}
+			if err := sink.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		if rt.Stop {
+			break
+		}
+	}
+	return firstErr
+}
+
+func (h *RouterHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return newAttrsHandler(h).WithAttrs(attrs)
+}
+
+func (h *RouterHandler) WithGroup(name string) slog.Handler {
+	return newAttrsHandler(h).WithGroup(name)
+}
@@ -0,0 +1,111 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouterHandler(t *testing.T) {
+	var audit, access, errs, rest bytes.Buffer
+	sink := func(buf *bytes.Buffer) slog.Handler {
+		return slog.NewTextHandler(buf, &slog.HandlerOptions{Level: LevelTrace})
+	}
+	h, err := NewRouterHandler(map[string]slog.Handler{
+		"audit":  sink(&audit),
+		"access": sink(&access),
+		"errors": sink(&errs),
+		"rest":   sink(&rest),
+	},
+		Route{Component: "audit", Sinks: []string{"audit"}, Stop: true},
+		Route{Attr: "http.method", Message: regexp.MustCompile(`^request`), Sinks: []string{"access"}, Stop: true},
+		Route{Levels: "error+", Sinks: []string{"errors", "rest"}},
+		Route{Sinks: []string{"rest"}},
+	)
+	if err != nil {
+		t.Fatalf("NewRouterHandler() error: %v", err)
+	}
+
+	logger := slog.New(h)
+	logger.With("component", "audit").Error("user deleted")
+	logger.WithGroup("http").Info("request served", "method", "GET")
+	logger.Info("response sent", slog.Group("http", "method", "GET"))
+	logger.Error("went wrong")
+
+	if !strings.Contains(audit.String(), "user deleted") || strings.Contains(errs.String()+rest.String(), "user deleted") {
+		t.Errorf("audit = %q, a stopping route should keep the record from other sinks", audit.String())
+	}
+	if !strings.Contains(access.String(), "request served") || strings.Contains(access.String(), "response sent") {
+		t.Errorf("access = %q", access.String())
+	}
+	if !strings.Contains(errs.String(), "went wrong") || strings.Count(rest.String(), "went wrong") != 1 {
+		t.Errorf("errors = %q, rest = %q, every sink should get a record once", errs.String(), rest.String())
+	}
+	if !strings.Contains(rest.String(), "response sent") {
+		t.Errorf("rest = %q", rest.String())
+	}
+}
+
+func TestRouterHandler_Package(t *testing.T) {
+	var own, other bytes.Buffer
+	h, _ := NewRouterHandler(map[string]slog.Handler{
+		"own":   slog.NewTextHandler(&own, nil),
+		"other": slog.NewTextHandler(&other, nil),
+	},
+		Route{Package: "github.com/tsisar/extended-log-go/log", Sinks: []string{"own"}, Stop: true},
+		Route{Sinks: []string{"other"}},
+	)
+
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "from the package", pcs[0]))
+	_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "without a caller", 0))
+	if !strings.Contains(own.String(), "from the package") || !strings.Contains(other.String(), "without a caller") {
+		t.Errorf("own = %q, other = %q", own.String(), other.String())
+	}
+}
+
+func TestFuncPackage(t *testing.T) {
+	tests := map[string]string{
+		"main.main": "main",
+		"github.com/tsisar/extended-log-go/log.Info":                  "github.com/tsisar/extended-log-go/log",
+		"github.com/tsisar/extended-log-go/log.(*FileHandler).Handle": "github.com/tsisar/extended-log-go/log",
+		"github.com/tsisar/extended-log-go/log.TestFuncPackage.func1": "github.com/tsisar/extended-log-go/log",
+		"gopkg.in/yaml%2ev3.(*Decoder).Decode":                        "gopkg.in/yaml.v3",
+		"gopkg.in/yaml%2ev3.Unmarshal":                                "gopkg.in/yaml.v3",
+		"example.com/api.v2/client%2ev1.New[...]":                     "example.com/api.v2/client.v1",
+	}
+	for name, want := range tests {
+		if got := funcPackage(name); got != want {
+			t.Errorf("funcPackage(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRouterHandler_Enabled(t *testing.T) {
+	h, _ := NewRouterHandler(map[string]slog.Handler{
+		"debug": slog.NewTextHandler(&bytes.Buffer{}, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	}, Route{Levels: "warn+", Sinks: []string{"debug"}})
+	ctx := context.Background()
+	if h.Enabled(ctx, slog.LevelInfo) || !h.Enabled(ctx, slog.LevelWarn) {
+		t.Error("only levels of a route with an enabled sink should be enabled")
+	}
+}
+
+func TestNewRouterHandler_Invalid(t *testing.T) {
+	sinks := map[string]slog.Handler{"file": slog.NewTextHandler(&bytes.Buffer{}, nil)}
+	for _, r := range []Route{
+		{},
+		{Sinks: []string{"missing"}},
+		{Levels: "loud+", Sinks: []string{"file"}},
+	} {
+		if _, err := NewRouterHandler(sinks, r); err == nil {
+			t.Errorf("NewRouterHandler(%+v) should fail", r)
+		}
+	}
+}
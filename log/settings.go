@@ -0,0 +1,251 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigError describes an invalid configuration value that was replaced by
+// its default.
+type ConfigError struct {
+	Variable string // e.g. LOG_LEVEL
+	Value    string // the invalid value
+	Err      error  // why the value is invalid
+	Default  string // the value used instead
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s value %q: %v; using %s", e.Variable, e.Value, e.Err, e.Default)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Validate returns the problems found when the configuration was read, one
+// *ConfigError per invalid value joined with errors.Join, or nil.
+func (c Config) Validate() error {
+	return errors.Join(c.errs...)
+}
+
+// CurrentConfig returns the configuration in effect.
+func CurrentConfig() Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return config
+}
+
+// setting is a configuration value as shown in reload and startup records.
+type setting struct {
+	name     string
+	variable string
+	value    string
+}
+
+func (c Config) settings() []setting {
+	slow := "(unset)"
+	if c.slowThreshold > 0 {
+		slow = c.slowThreshold.String()
+	}
+	return []setting{
+		{"save", c.prefix + "SAVE", strconv.FormatBool(c.save)},
+		{"level", c.prefix + "LEVEL", levelName(c.level)},
+		{"timezone", c.prefix + "TIMEZONE", settingString(c.timezone)},
+		{"directory", c.prefix + "DIRECTORY", settingString(c.directory)},
+		{"file_name", c.prefix + "FILE_NAME", settingString(c.fileName)},
+		{"files", c.prefix + "FILES", settingString(c.filesSpec)},
+		{"file_mode", c.prefix + "FILE_MODE", fmt.Sprintf("%#o", c.fileMode)},
+		{"dir_mode", c.prefix + "DIR_MODE", fmt.Sprintf("%#o", c.dirMode)},
+		{"current_link", c.prefix + "CURRENT_LINK", settingString(c.currentLink)},
+		{"process_mode", c.prefix + "PROCESS_MODE", c.processMode},
+		{"socket", c.prefix + "SOCKET", settingString(c.socket)},
+		{"format", c.prefix + "FORMAT", c.format},
+		{"message_policy", c.prefix + "MESSAGE_POLICY", c.messagePolicy},
+		{"rotation", c.prefix + "ROTATION", formatRotation(c.rotation)},
+		{"retention", c.retentionVariable(), formatRetention(c.retention)},
+		{"max_total_size", c.prefix + "MAX_TOTAL_SIZE", limit(c.maxTotalSize)},
+		{"max_files", c.prefix + "MAX_FILES", limit(int64(c.maxFiles))},
+		{"show_caller", c.prefix + "SHOW_CALLER", strconv.FormatBool(c.showCaller)},
+		{"ring_buffer", c.prefix + "RING_BUFFER", strconv.Itoa(c.ringSize)},
+		{"ring_flush_on_error", c.prefix + "RING_FLUSH_ON_ERROR", strconv.FormatBool(c.ringFlush)},
+		{"slow_threshold", c.prefix + "SLOW_THRESHOLD", slow},
+		{"file_fallback", c.prefix + "FILE_FALLBACK", strconv.FormatBool(c.fileFallback)},
+	}
+}
+
+// retentionVariable returns the variable the retention was read from.
+func (c Config) retentionVariable() string {
+	if getenv(c.prefix+"RETENTION") == "" && getenv(c.prefix+"RETENTION_DAYS") != "" {
+		return c.prefix + "RETENTION_DAYS"
+	}
+	return c.prefix + "RETENTION"
+}
+
+// formatRotation returns the name of a rotation interval as accepted by LOG_ROTATION.
+func formatRotation(interval time.Duration) string {
+	switch interval {
+	case 0, day:
+		return "daily"
+	case time.Hour:
+		return "hourly"
+	case week:
+		return "weekly"
+	}
+	return strings.TrimSuffix(interval.String(), "0s")
+}
+
+// formatRetention returns a retention period in days where possible, e.g. "30d".
+func formatRetention(d time.Duration) string {
+	if d > 0 && d%day == 0 {
+		return strconv.Itoa(int(d/day)) + "d"
+	}
+	return d.String()
+}
+
+// limit formats a limit where 0 means none.
+func limit(n int64) string {
+	if n == 0 {
+		return "(unset)"
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func settingString(s string) string {
+	if s == "" {
+		return "(unset)"
+	}
+	return s
+}
+
+// source describes where the value of a variable came from: "env", the name
+// of a configuration file or "default".
+func (c Config) source(variable string) string {
+	for _, err := range c.errs {
+		if ce, ok := err.(*ConfigError); ok && ce.Variable == variable {
+			return "default"
+		}
+	}
+	fileMu.RLock()
+	name, ok := fileSources[variable]
+	fileMu.RUnlock()
+	if ok {
+		return name
+	}
+	if value, ok := os.LookupEnv(variable); ok && value != "" {
+		return "env"
+	}
+	return "default"
+}
+
+// reportConfig logs the effective configuration with the source of every
+// value, e.g. config.level="info (env)" config.format="json (.env)".
+func reportConfig(cfg Config) {
+	settings := cfg.settings()
+	attrs := make([]any, 0, len(settings))
+	for _, s := range settings {
+		attrs = append(attrs, slog.String(s.name, s.value+" ("+cfg.source(s.variable)+")"))
+	}
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "Logging configured", slog.Group("config", attrs...))
+}
+
+// parseBool accepts true/false, 1/0, yes/no, on/off, y/n and t/f in any case.
+// An empty value is false.
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "1", "yes", "on", "y", "t":
+		return true, nil
+	case "", "false", "0", "no", "off", "n", "f":
+		return false, nil
+	}
+	return false, errors.New("must be true or false")
+}
+
+// parseLevelValue accepts the level names, slog's "INFO+2" notation and
+// numeric levels.
+func parseLevelValue(s string) (slog.Level, error) {
+	if level, ok := parseLevel(s); ok {
+		return level, nil
+	}
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		return slog.Level(n), nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err == nil {
+		return level, nil
+	}
+	return 0, errors.New("must be trace, debug, info, warn or error")
+}
+
+// levelName returns the lower-case name of a level, as accepted by LOG_LEVEL.
+func levelName(level slog.Level) string {
+	if level == LevelTrace {
+		return "trace"
+	}
+	return strings.ToLower(level.String())
+}
+
+// parseDuration accepts time.ParseDuration values in any case as well as
+// whole days and weeks such as "7d" or "2w".
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, err := strconv.Atoi(strings.TrimSuffix(s, suffix)); err == nil && strings.HasSuffix(s, suffix) {
+			return time.Duration(n) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
+// parseSize parses a byte size such as "512", "10KB", "1.5 MiB" or "2g".
+// Decimal (KB, MB, GB) and binary (KiB, MiB, GiB) units are accepted; single
+// letters are binary.
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], strings.TrimSpace(s[i:])
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	var mult float64
+	switch strings.ToLower(unit) {
+	case "", "b":
+		mult = 1
+	case "kb":
+		mult = 1e3
+	case "mb":
+		mult = 1e6
+	case "gb":
+		mult = 1e9
+	case "tb":
+		mult = 1e12
+	case "k", "kib":
+		mult = 1 << 10
+	case "m", "mib":
+		mult = 1 << 20
+	case "g", "gib":
+		mult = 1 << 30
+	case "t", "tib":
+		mult = 1 << 40
+	default:
+		return 0, fmt.Errorf("invalid size unit %q", unit)
+	}
+	if n*mult > math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return int64(n * mult), nil
+}
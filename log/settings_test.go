@@ -0,0 +1,152 @@
+package log
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBool(t *testing.T) {
+	for _, s := range []string{"true", "TRUE", " 1 ", "yes", "On", "y", "t"} {
+		if b, err := parseBool(s); err != nil || !b {
+			t.Errorf("parseBool(%q) = %v, %v; want true", s, b, err)
+		}
+	}
+	for _, s := range []string{"", "false", "0", "NO", "off", "n", "F"} {
+		if b, err := parseBool(s); err != nil || b {
+			t.Errorf("parseBool(%q) = %v, %v; want false", s, b, err)
+		}
+	}
+	if _, err := parseBool("enabled"); err == nil {
+		t.Error("parseBool(enabled) should fail")
+	}
+}
+
+func TestParseLevelValue(t *testing.T) {
+	tests := map[string]slog.Level{
+		"trace":   LevelTrace,
+		" DEBUG ": slog.LevelDebug,
+		"Warning": slog.LevelWarn,
+		"INFO+2":  slog.LevelInfo + 2,
+		"-4":      slog.LevelDebug,
+	}
+	for s, want := range tests {
+		if got, err := parseLevelValue(s); err != nil || got != want {
+			t.Errorf("parseLevelValue(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := parseLevelValue("verbose"); err == nil {
+		t.Error("parseLevelValue(verbose) should fail")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"500ms": 500 * time.Millisecond,
+		" 2S ":  2 * time.Second,
+		"1h30m": 90 * time.Minute,
+		"7d":    7 * 24 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+	}
+	for s, want := range tests {
+		if got, err := parseDuration(s); err != nil || got != want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	if _, err := parseDuration("soon"); err == nil {
+		t.Error("parseDuration(soon) should fail")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"512":     512,
+		"10KB":    10_000,
+		"1.5 MiB": 1_572_864,
+		"2g":      2 << 30,
+		"1gb":     1_000_000_000,
+	}
+	for s, want := range tests {
+		if got, err := parseSize(s); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "MB", "-1", "10 parsecs"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("parseSize(%q) should fail", s)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "verbose")
+	t.Setenv("LOG_SAVE", "TRUE")
+	t.Setenv("LOG_FORMAT", "xml")
+	t.Setenv("LOG_SHOW_CALLER", "sometimes")
+	t.Setenv("LOG_SLOW_THRESHOLD", "1D")
+	setupReload(t)
+
+	cfg := loadConfig("LOG_")
+	if !cfg.save || cfg.level != LevelTrace || cfg.format != formatText || cfg.slowThreshold != 24*time.Hour {
+		t.Errorf("save = %v, level = %v, format = %s, slow = %v", cfg.save, cfg.level, cfg.format, cfg.slowThreshold)
+	}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate() should report the invalid values")
+	}
+	for _, want := range []string{`invalid LOG_LEVEL value "verbose"`, "LOG_FORMAT", "LOG_SHOW_CALLER"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, should contain %s", err, want)
+		}
+	}
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Variable != "LOG_LEVEL" || ce.Default != "trace" {
+		t.Errorf("errors.As() = %+v", ce)
+	}
+}
+
+func TestReload_StrictRejectsInvalidConfig(t *testing.T) {
+	t.Setenv("LOG_STRICT", "yes")
+	setupReload(t)
+
+	writeFile(t, ".env", "LOG_LEVEL=info\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	writeFile(t, ".env", "LOG_LEVEL=loud\nLOG_FORMAT=json\n")
+	if err := Reload(); err == nil || !strings.Contains(err.Error(), "LOG_LEVEL") {
+		t.Errorf("Reload() error = %v, want the invalid LOG_LEVEL", err)
+	}
+	if logLevel.Level() != slog.LevelInfo || config.format != formatText {
+		t.Errorf("the previous configuration should be kept, level = %v, format = %s", logLevel.Level(), config.format)
+	}
+	// The rejected values are not visible to readers of the configuration either
+	if getenv("LOG_LEVEL") != "info" || os.Getenv("LOG_FORMAT") != "" || ConfigFromEnvPrefix("LOG_").format != formatText {
+		t.Errorf("LOG_LEVEL = %q, LOG_FORMAT = %q after a rejected reload", getenv("LOG_LEVEL"), os.Getenv("LOG_FORMAT"))
+	}
+}
+
+func TestReportConfig(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("LOG_RETENTION_DAYS", "many")
+	t.Setenv("LOG_RETENTION", "")
+	buf := setupReload(t)
+
+	writeFile(t, ".env", "LOG_FORMAT=logfmt\n")
+	if err := loadConfigFiles(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := loadConfig("LOG_")
+	reportConfig(cfg)
+
+	out := buf.String()
+	for _, want := range []string{"Logging configured", `config.level="warn (env)"`, `config.format="logfmt (.env)"`, `config.retention="30d (default)"`, `config.save="false (default)"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report should contain %s, got %q", want, out)
+		}
+	}
+}
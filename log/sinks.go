@@ -0,0 +1,188 @@
+package log
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/tsisar/extended-log-go/internal/filename"
+)
+
+// noMaxLevel is the maximum of a level range without an upper bound.
+const noMaxLevel = slog.Level(math.MaxInt)
+
+// levelRange is the levels written to a log file, e.g. "warn+" or "debug-info".
+type levelRange struct {
+	min, max slog.Level
+}
+
+func (r levelRange) contains(level slog.Level) bool {
+	return level >= r.min && level <= r.max
+}
+
+func (r levelRange) String() string {
+	switch {
+	case r.max == noMaxLevel:
+		return levelName(r.min) + "+"
+	case r.min == r.max:
+		return levelName(r.min)
+	}
+	return levelName(r.min) + "-" + levelName(r.max)
+}
+
+// parseLevelRange parses "warn+" (warn and above), "debug-info" (debug to
+// info) or "error" (error only).
+func parseLevelRange(s string) (levelRange, error) {
+	s = strings.TrimSpace(s)
+	if min, ok := strings.CutSuffix(s, "+"); ok {
+		level, err := parseLevelValue(min)
+		return levelRange{level, noMaxLevel}, err
+	}
+	if min, max, ok := strings.Cut(s, "-"); ok && min != "" {
+		lo, err := parseLevelValue(min)
+		if err != nil {
+			return levelRange{}, err
+		}
+		hi, err := parseLevelValue(max)
+		if err != nil {
+			return levelRange{}, err
+		}
+		if hi < lo {
+			return levelRange{}, fmt.Errorf("level range %q is empty", s)
+		}
+		return levelRange{lo, hi}, nil
+	}
+	level, err := parseLevelValue(s)
+	return levelRange{level, level}, err
+}
+
+// fileSink is a log file of LOG_FILES with its own level range and name.
+type fileSink struct {
+	name     string
+	levels   levelRange
+	fileName string
+	files    *filename.Template
+}
+
+var sinkName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseFileSinks parses LOG_FILES, a comma-separated list of name:levels or
+// name:levels:template entries such as "all:trace+,errors:warn+". The first
+// file uses mainName, the others insert ".name" before its extension unless
+// they have a template of their own, to which pid adds {pid}.
+func parseFileSinks(s, mainName, app string, pid bool) ([]fileSink, error) {
+	var sinks []fileSink
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		name, rest, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("%q must be name:levels", entry)
+		}
+		if !sinkName.MatchString(name) {
+			return nil, fmt.Errorf("invalid file name %q", name)
+		}
+		levels, fileName, _ := strings.Cut(rest, ":")
+		r, err := parseLevelRange(levels)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		switch {
+		case fileName == "" && len(sinks) == 0:
+			fileName = mainName
+		case fileName == "":
+			fileName = filename.InsertBeforeExt(mainName, "."+name)
+		case pid:
+			fileName = filename.WithPID(fileName)
+		}
+		if seen[name] || seen[fileName] {
+			return nil, fmt.Errorf("%s: duplicate file", name)
+		}
+		seen[name], seen[fileName] = true, true
+
+		files, err := filename.Parse(fileName, app)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q %w", name, fileName, err)
+		}
+		sinks = append(sinks, fileSink{name: name, levels: r, fileName: fileName, files: files})
+	}
+	if len(sinks) == 0 {
+		return nil, errors.New("no files")
+	}
+	return sinks, nil
+}
+
+// newFileSinkHandlers returns a file handler for every file of LOG_FILES, or
+// a single one for LOG_FILE_NAME at level. The first handler removes the old
+// files of all of them.
+func newFileSinkHandlers(conf *Config, level slog.Leveler, basePath string, aead cipher.AEAD) []*FileHandler {
+	if len(conf.fileSinks) == 0 {
+		return []*FileHandler{newConfiguredFileHandler(conf, level, basePath, aead)}
+	}
+	retained := make([]*filename.Template, len(conf.fileSinks))
+	for i, sink := range conf.fileSinks {
+		retained[i] = sink.files
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	handlers := make([]*FileHandler, len(conf.fileSinks))
+	for i, sink := range conf.fileSinks {
+		h := &FileHandler{
+			basePath:  basePath,
+			conf:      conf,
+			aead:      aead,
+			level:     sink.levels.min,
+			name:      sink.name,
+			files:     sink.files,
+			levels:    &sink.levels,
+			secondary: i > 0,
+			noLink:    i > 0,
+			retained:  retained,
+		}
+		handlers[i] = h.open()
+	}
+	return handlers
+}
+
+// FileOptions configures a FileHandler created with NewFileHandler.
+type FileOptions struct {
+	// Name is the file name template, e.g. "{date}.error.log". LOG_FILE_NAME
+	// is used if it is empty.
+	Name string
+	// Levels are the levels written to the file, e.g. "warn+", "debug-info"
+	// or "error". LOG_LEVEL applies if it is empty.
+	Levels string
+}
+
+// NewFileHandler returns a handler writing to files in dir with the format,
+// rotation, retention and encryption of the package-level logger. The
+// retention policies apply to the files named by its own template. Close it
+// when it is no longer used.
+func NewFileHandler(dir string, opts FileOptions) (*FileHandler, error) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	h := &FileHandler{basePath: dir, conf: &config, level: logLevel, noLink: true}
+	if opts.Name != "" {
+		files, err := filename.Parse(opts.Name, config.appName)
+		if err != nil {
+			return nil, fmt.Errorf("log: file name %q: %w", opts.Name, err)
+		}
+		h.files = files
+	}
+	if opts.Levels != "" {
+		r, err := parseLevelRange(opts.Levels)
+		if err != nil {
+			return nil, fmt.Errorf("log: levels: %w", err)
+		}
+		h.level, h.levels = r.min, &r
+	}
+	if fileHandler != nil {
+		h.aead = fileHandler.aead
+	}
+	return h.open(), nil
+}
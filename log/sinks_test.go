@@ -0,0 +1,128 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevelRange(t *testing.T) {
+	tests := []struct {
+		input string
+		want  levelRange
+	}{
+		{"trace+", levelRange{LevelTrace, noMaxLevel}},
+		{"WARN+", levelRange{slog.LevelWarn, noMaxLevel}},
+		{"debug-info", levelRange{slog.LevelDebug, slog.LevelInfo}},
+		{"error", levelRange{slog.LevelError, slog.LevelError}},
+	}
+	for _, tt := range tests {
+		got, err := parseLevelRange(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("parseLevelRange(%q) = %v, %v, want %v", tt.input, got, err, tt.want)
+		}
+		if s := got.String(); !strings.EqualFold(s, tt.input) {
+			t.Errorf("String() = %s, want %s", s, tt.input)
+		}
+	}
+	for _, s := range []string{"", "loud+", "info-debug", "warn-"} {
+		if _, err := parseLevelRange(s); err == nil {
+			t.Errorf("parseLevelRange(%q) should fail", s)
+		}
+	}
+}
+
+func TestParseFileSinks(t *testing.T) {
+	sinks, err := parseFileSinks("all:trace+, errors:warn+, audit:info-info:audit/{date}.log", "{date}.log", "app", false)
+	if err != nil {
+		t.Fatalf("parseFileSinks() error: %v", err)
+	}
+	want := []string{"{date}.log", "{date}.errors.log", "audit/{date}.log"}
+	for i, sink := range sinks {
+		if sink.fileName != want[i] {
+			t.Errorf("file %d = %s, want %s", i, sink.fileName, want[i])
+		}
+	}
+	if sinks[1].name != "errors" || sinks[1].levels.min != slog.LevelWarn {
+		t.Errorf("errors file = %+v", sinks[1])
+	}
+
+	sinks, _ = parseFileSinks("all:info+,errors:error+:{date}.error.log", "{date}-{pid}.log", "app", true)
+	if sinks[1].fileName != "{date}.error-{pid}.log" {
+		t.Errorf("pid mode file name = %s", sinks[1].fileName)
+	}
+
+	for _, s := range []string{"all", "all:loud+", "all:trace+,all:warn+", "a b:info+", "all:info+,errors:warn+:{date}.log", "x:info+:nodate.log"} {
+		if _, err := parseFileSinks(s, "{date}.log", "app", false); err == nil {
+			t.Errorf("parseFileSinks(%q) should fail", s)
+		}
+	}
+}
+
+func TestReload_ErrorLogFile(t *testing.T) {
+	withFormat(t, formatText, false)
+	setupReload(t)
+	dir := t.TempDir()
+
+	// A week-old error file is removed with the main files
+	old := filepath.Join(dir, time.Now().AddDate(0, 0, -7).Format("2006-01-02")+".error.log")
+	writeFile(t, old, "old\n")
+
+	writeFile(t, ".env", "LOG_SAVE=true\nLOG_DIRECTORY="+dir+"\nLOG_FILES=all:trace+,errors:warn+:{date}.error.log\nLOG_RETENTION=3d\n")
+	if err := Reload(); err != nil {
+		t.Fatalf("Reload() error: %v", err)
+	}
+	if len(fileHandlers) != 2 || fileHandler != fileHandlers[0] {
+		t.Fatalf("file handlers = %v", fileHandlers)
+	}
+
+	// Log to the file sinks only, the console writes to stdout
+	files := slog.New(newMultiHandler(fileHandlers[0], fileHandlers[1]))
+	files.Info("all good")
+	files.Error("went wrong")
+	for _, h := range fileHandlers {
+		h.Close()
+	}
+
+	today := time.Now().In(config.loc()).Format("2006-01-02")
+	all, _ := os.ReadFile(filepath.Join(dir, today+".log"))
+	errs, _ := os.ReadFile(filepath.Join(dir, today+".error.log"))
+	if !strings.Contains(string(all), "all good") || !strings.Contains(string(all), "went wrong") {
+		t.Errorf("main log = %q", all)
+	}
+	if strings.Contains(string(errs), "all good") || !strings.Contains(string(errs), "went wrong") {
+		t.Errorf("error log = %q", errs)
+	}
+	if exists(old) {
+		t.Error("old error log files should be removed by the retention cleanup")
+	}
+}
+
+func TestNewFileHandler(t *testing.T) {
+	withFormat(t, formatText, false)
+	dir := t.TempDir()
+
+	h, err := NewFileHandler(dir, FileOptions{Name: "{date}.debug.log", Levels: "debug-debug"})
+	if err != nil {
+		t.Fatalf("NewFileHandler() error: %v", err)
+	}
+	defer h.Close()
+	ctx := context.Background()
+	if h.Enabled(ctx, slog.LevelInfo) || !h.Enabled(ctx, slog.LevelDebug) {
+		t.Error("only debug records should be enabled")
+	}
+	if err := h.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelDebug, "details", 0)); err != nil {
+		t.Fatalf("Handle() error: %v", err)
+	}
+	if data, _ := os.ReadFile(h.path); filepath.Base(h.path) != time.Now().In(config.loc()).Format("2006-01-02")+".debug.log" || !strings.Contains(string(data), "details") {
+		t.Errorf("%s = %q", h.path, data)
+	}
+
+	if _, err := NewFileHandler(dir, FileOptions{Levels: "some"}); err == nil {
+		t.Error("NewFileHandler() should reject invalid levels")
+	}
+}
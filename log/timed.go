@@ -0,0 +1,80 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// Timer measures the duration of an operation started with Timed.
+type Timer struct {
+	name      string
+	args      []any
+	start     time.Time
+	threshold time.Duration
+}
+
+// Timed logs the start of the operation name at Trace level and returns a
+// Timer whose End logs its completion with the duration:
+//
+//	defer log.Timed("db.query", "table", "orders").End()
+//
+// args are key-value pairs or slog.Attr values added to both records.
+func Timed(name string, args ...any) *Timer {
+	configMu.RLock()
+	t := &Timer{name: name, args: args, threshold: config.slowThreshold}
+	configMu.RUnlock()
+	t.log(LevelTrace, name+" started", nil)
+	t.start = time.Now()
+	return t
+}
+
+// Slow sets the duration above which the completion is logged at Warn level
+// with slow=true, overriding LOG_SLOW_THRESHOLD. Zero disables the check.
+func (t *Timer) Slow(threshold time.Duration) *Timer {
+	t.threshold = threshold
+	return t
+}
+
+// End logs the completion of the operation at Debug level with its duration.
+// Slow operations are logged at Warn level. If a non-nil error is passed, it
+// is added as the err attribute and the completion is logged at Error level.
+//
+// To pass the error of a function with a named result, defer a closure:
+//
+//	t := log.Timed("sync")
+//	defer func() { t.End(err) }()
+func (t *Timer) End(err ...error) time.Duration {
+	d := time.Since(t.start)
+	level := slog.LevelDebug
+	extra := []any{slog.Duration("duration", d)}
+	if t.threshold > 0 && d > t.threshold {
+		level = slog.LevelWarn
+		extra = append(extra, slog.Bool("slow", true))
+	}
+	for _, e := range err {
+		if e != nil {
+			level = slog.LevelError
+			extra = append(extra, slog.Any("err", e))
+			break
+		}
+	}
+	t.log(level, t.name+" finished", extra)
+	return d
+}
+
+// log writes a record with the caller of the Timer method as its source, the
+// way logWithPC does for the package-level functions.
+func (t *Timer) log(level slog.Level, msg string, extra []any) {
+	ctx := context.Background()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip: runtime.Callers, log, Timed or End
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(t.args...)
+	r.Add(extra...)
+	_ = logger.Handler().Handle(ctx, r)
+}
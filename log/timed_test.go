@@ -0,0 +1,52 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimed_LogsStartAndEnd(t *testing.T) {
+	var buf bytes.Buffer
+	cleanup := setupTestLogger(&buf)
+	defer cleanup()
+
+	func() {
+		defer Timed("db.query", "table", "orders").End()
+	}()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", buf.String())
+	}
+	if !strings.Contains(lines[0], "TRACE") || !strings.Contains(lines[0], "db.query started | table=orders") {
+		t.Errorf("unexpected start line %q", lines[0])
+	}
+	if !strings.Contains(lines[1], "DEBUG") || !strings.Contains(lines[1], "db.query finished | table=orders duration=") {
+		t.Errorf("unexpected end line %q", lines[1])
+	}
+	for _, line := range lines {
+		if caller := extractCaller(line); !strings.HasPrefix(caller, "timed_test.go:") {
+			t.Errorf("expected caller timed_test.go:*, got %s", caller)
+		}
+	}
+}
+
+func TestTimed_SlowAndError(t *testing.T) {
+	var buf bytes.Buffer
+	cleanup := setupTestLogger(&buf)
+	defer cleanup()
+
+	Timed("export").Slow(time.Nanosecond).End(nil)
+	if !strings.Contains(buf.String(), "WARN") || !strings.Contains(buf.String(), "slow=true") {
+		t.Errorf("slow operation should be logged at Warn, got %q", buf.String())
+	}
+
+	buf.Reset()
+	Timed("import").End(errors.New("disk full"))
+	if !strings.Contains(buf.String(), "ERROR") || !strings.Contains(buf.String(), `err="disk full"`) {
+		t.Errorf("failed operation should be logged at Error, got %q", buf.String())
+	}
+}
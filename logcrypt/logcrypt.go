@@ -0,0 +1,223 @@
+// Package logcrypt implements the encrypted-at-rest format used for log files.
+//
+// An encrypted file starts with a short magic header followed by a sequence of
+// independent segments. Every segment is one AES-GCM sealed chunk (usually a
+// single log line) with its own random nonce:
+//
+//	[4-byte big-endian length][12-byte nonce][ciphertext + 16-byte tag]
+//
+// Segments are self-contained, so files can be appended to across process
+// restarts and read back in a streaming fashion.
+package logcrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Extension is appended to the name of encrypted log files.
+const Extension = ".enc"
+
+// EnvKey is the environment variable holding the default encryption key.
+const EnvKey = "LOG_ENCRYPTION_KEY"
+
+// Magic is the header written at the beginning of every encrypted file.
+var Magic = []byte("ELOGENC1")
+
+// maxSegment bounds the size of a single segment to protect readers from
+// corrupted length prefixes.
+const maxSegment = 16 << 20
+
+// ErrInvalidHeader is returned when a file does not start with Magic.
+var ErrInvalidHeader = errors.New("logcrypt: invalid file header")
+
+// ErrTruncated is returned when the input ends in the middle of a segment.
+// The bytes read so far are kept, so a file that is still being written can
+// be read again once the rest of the segment has been appended.
+var ErrTruncated = errors.New("logcrypt: truncated segment")
+
+// KeyProvider supplies the AES key used to encrypt log files.
+type KeyProvider interface {
+	Key() ([]byte, error)
+}
+
+// KeyFunc adapts an ordinary function to the KeyProvider interface.
+type KeyFunc func() ([]byte, error)
+
+// Key returns f().
+func (f KeyFunc) Key() ([]byte, error) {
+	return f()
+}
+
+// EnvKeyProvider returns a KeyProvider that reads a hex or base64 encoded key
+// from the named environment variable.
+func EnvKeyProvider(name string) KeyProvider {
+	return KeyFunc(func() ([]byte, error) {
+		value := os.Getenv(name)
+		if value == "" {
+			return nil, fmt.Errorf("logcrypt: %s is not set", name)
+		}
+		return ParseKey(value)
+	})
+}
+
+// ParseKey decodes a hex or base64 encoded AES-128, AES-192 or AES-256 key.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && validKeySize(len(key)) {
+		return key, nil
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if key, err := enc.DecodeString(s); err == nil && validKeySize(len(key)) {
+			return key, nil
+		}
+	}
+	return nil, errors.New("logcrypt: key must be 16, 24 or 32 bytes encoded as hex or base64")
+}
+
+func validKeySize(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+// NewAEAD creates an AES-GCM cipher for the given key.
+func NewAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("logcrypt: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// Writer seals every Write call into a separate segment.
+type Writer struct {
+	w    io.Writer
+	aead cipher.AEAD
+}
+
+// NewWriter returns a Writer that encrypts segments to w. The caller is
+// responsible for writing the header to empty files with WriteHeader.
+func NewWriter(w io.Writer, aead cipher.AEAD) *Writer {
+	return &Writer{w: w, aead: aead}
+}
+
+// WriteHeader writes the magic header identifying an encrypted file.
+func (w *Writer) WriteHeader() error {
+	_, err := w.w.Write(Magic)
+	return err
+}
+
+// Write encrypts p as a single segment. The whole segment is written with one
+// call to the underlying writer so that appends stay atomic.
+func (w *Writer) Write(p []byte) (int, error) {
+	nonceSize := w.aead.NonceSize()
+	buf := make([]byte, 4+nonceSize, 4+nonceSize+len(p)+w.aead.Overhead())
+	nonce := buf[4 : 4+nonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, fmt.Errorf("logcrypt: generate nonce: %w", err)
+	}
+	buf = w.aead.Seal(buf, nonce, p, nil)
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(buf)-4))
+	if _, err := w.w.Write(buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Reader decrypts a stream produced by Writer, including its header.
+type Reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	started bool
+	buf     []byte
+	seg     []byte // bytes of the header or segment being read
+}
+
+// NewReader returns a Reader that decrypts the encrypted file read from r.
+func NewReader(r io.Reader, aead cipher.AEAD) *Reader {
+	return &Reader{r: bufio.NewReader(r), aead: aead}
+}
+
+// Read returns decrypted plaintext. Segments that fail authentication are
+// reported as errors rather than skipped. At the end of the input Read
+// returns io.EOF, or ErrTruncated in the middle of a segment; reading may
+// continue after either once more data is available.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *Reader) next() error {
+	if !r.started {
+		if err := r.fill(len(Magic)); err != nil {
+			return err
+		}
+		if string(r.seg) != string(Magic) {
+			return ErrInvalidHeader
+		}
+		r.started = true
+		r.seg = r.seg[:0]
+	}
+
+	if err := r.fill(4); err != nil {
+		return err
+	}
+	size := int(binary.BigEndian.Uint32(r.seg[:4]))
+	nonceSize := r.aead.NonceSize()
+	if size < nonceSize+r.aead.Overhead() || size > maxSegment {
+		return fmt.Errorf("logcrypt: invalid segment length %d", size)
+	}
+	if err := r.fill(4 + size); err != nil {
+		return err
+	}
+	segment := r.seg[4:]
+	plain, err := r.aead.Open(segment[nonceSize:nonceSize], segment[:nonceSize], segment[nonceSize:], nil)
+	if err != nil {
+		return fmt.Errorf("logcrypt: decrypt segment: %w", err)
+	}
+	r.buf = plain
+	r.seg = r.seg[:0]
+	return nil
+}
+
+// fill reads until the current header or segment has n bytes. It returns
+// io.EOF if the input ends before it and ErrTruncated if it ends within it.
+func (r *Reader) fill(n int) error {
+	if len(r.seg) >= n {
+		return nil
+	}
+	if cap(r.seg) < n {
+		r.seg = append(make([]byte, 0, n), r.seg...)
+	}
+	m, err := io.ReadFull(r.r, r.seg[len(r.seg):n])
+	r.seg = r.seg[:len(r.seg)+m]
+	switch {
+	case err == nil:
+		return nil
+	case len(r.seg) == 0 && err == io.EOF:
+		return io.EOF
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return ErrTruncated
+	}
+	return err
+}
+
+// IsEncrypted reports whether name looks like an encrypted log file.
+func IsEncrypted(name string) bool {
+	return strings.HasSuffix(name, Extension)
+}
@@ -0,0 +1,118 @@
+package logcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		ok    bool
+	}{
+		{"hex", hex.EncodeToString(testKey), true},
+		{"base64", base64.StdEncoding.EncodeToString(testKey), true},
+		{"hex 16 bytes", hex.EncodeToString(testKey[:16]), true},
+		{"too short", hex.EncodeToString(testKey[:10]), false},
+		{"garbage", "not a key", false},
+	}
+
+	for _, tt := range tests {
+		key, err := ParseKey(tt.input)
+		if tt.ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%s: expected error, got key of %d bytes", tt.name, len(key))
+		}
+	}
+}
+
+func TestWriterReader_RoundTrip(t *testing.T) {
+	aead, err := NewAEAD(testKey)
+	if err != nil {
+		t.Fatalf("NewAEAD() error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, aead)
+	if err := w.WriteHeader(); err != nil {
+		t.Fatalf("WriteHeader() error: %v", err)
+	}
+	lines := []string{"first line\n", "second line\n", "third line\n"}
+	for _, line := range lines {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("second line")) {
+		t.Fatal("ciphertext contains plaintext")
+	}
+
+	data, err := io.ReadAll(NewReader(&buf, aead))
+	if err != nil {
+		t.Fatalf("ReadAll() error: %v", err)
+	}
+	if got, want := string(data), "first line\nsecond line\nthird line\n"; got != want {
+		t.Errorf("decrypted = %q, want %q", got, want)
+	}
+}
+
+func TestReader_WrongKey(t *testing.T) {
+	aead, _ := NewAEAD(testKey)
+	other, _ := NewAEAD(bytes.Repeat([]byte{0x01}, 32))
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, aead)
+	_ = w.WriteHeader()
+	_, _ = w.Write([]byte("secret\n"))
+
+	if _, err := io.ReadAll(NewReader(&buf, other)); err == nil {
+		t.Error("expected authentication error with the wrong key")
+	}
+}
+
+func TestReader_InvalidHeader(t *testing.T) {
+	aead, _ := NewAEAD(testKey)
+	_, err := io.ReadAll(NewReader(bytes.NewBufferString("plain text log\n"), aead))
+	if !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestReader_ResumesTruncatedSegment(t *testing.T) {
+	aead, _ := NewAEAD(testKey)
+	var enc bytes.Buffer
+	w := NewWriter(&enc, aead)
+	_ = w.WriteHeader()
+	_, _ = w.Write([]byte("first\n"))
+	_, _ = w.Write([]byte("second\n"))
+	data := enc.Bytes()
+
+	// The file is read while it is being written, a few bytes at a time
+	var file bytes.Buffer
+	r := NewReader(&file, aead)
+	var got []byte
+	for written := 0; written < len(data); {
+		n := min(5, len(data)-written)
+		file.Write(data[written : written+n])
+		written += n
+
+		b, err := io.ReadAll(r)
+		got = append(got, b...)
+		if written < len(data) && err != nil && !errors.Is(err, ErrTruncated) {
+			t.Fatalf("ReadAll() error after %d bytes: %v", written, err)
+		}
+	}
+	if string(got) != "first\nsecond\n" {
+		t.Errorf("decrypted = %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package logparse
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// parseJSON parses a line written in the JSON format, keeping the order of
+// the attributes.
+func parseJSON(line string, loc *time.Location) (Entry, error) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return Entry{}, ErrFormat
+	}
+
+	var e Entry
+	var hasTime, hasLevel bool
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return Entry{}, ErrFormat
+		}
+		key, _ := tok.(string)
+
+		var value any
+		if err := dec.Decode(&value); err != nil {
+			return Entry{}, ErrFormat
+		}
+		s, isString := value.(string)
+
+		switch {
+		case key == "time" && isString:
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return Entry{}, ErrFormat
+			}
+			e.Time = t.In(loc)
+			hasTime = true
+		case key == "level" && isString:
+			level, err := ParseLevel(s)
+			if err != nil {
+				return Entry{}, ErrFormat
+			}
+			e.Level = level
+			hasLevel = true
+		case key == "caller" && isString:
+			e.Caller = s
+		case key == "msg" && isString:
+			e.Message = s
+		default:
+			e.Attrs = append(e.Attrs, slog.Attr{Key: key, Value: jsonValue(value)})
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return Entry{}, ErrFormat
+	}
+	if !hasTime || !hasLevel {
+		return Entry{}, ErrFormat
+	}
+	return e, nil
+}
+
+// jsonValue converts a decoded JSON value to a slog.Value.
+func jsonValue(v any) slog.Value {
+	switch v := v.(type) {
+	case string:
+		return slog.StringValue(v)
+	case bool:
+		return slog.BoolValue(v)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return slog.Int64Value(i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return slog.Float64Value(f)
+		}
+		return slog.StringValue(v.String())
+	default:
+		return slog.AnyValue(v)
+	}
+}
@@ -0,0 +1,109 @@
+package logparse
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// pair is a single key=value token.
+type pair struct {
+	key   string
+	value string
+}
+
+// parsePairs splits a logfmt string into key=value pairs. Values may be bare
+// or double-quoted with Go escapes. Any token without "=" is an error.
+func parsePairs(s string) ([]pair, error) {
+	var pairs []pair
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return pairs, nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.ContainsAny(s[:eq], " \"") {
+			return nil, ErrFormat
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, ErrFormat
+			}
+			if value, err = strconv.Unquote(quoted); err != nil {
+				return nil, ErrFormat
+			}
+			s = s[len(quoted):]
+			if s != "" && s[0] != ' ' {
+				return nil, ErrFormat
+			}
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value = s[:end]
+			s = s[end:]
+		}
+		pairs = append(pairs, pair{key: key, value: value})
+	}
+}
+
+// parseLogfmt parses a line written in the logfmt format.
+func parseLogfmt(line string, loc *time.Location) (Entry, error) {
+	pairs, err := parsePairs(line)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var e Entry
+	var hasTime, hasLevel bool
+	for _, p := range pairs {
+		switch p.key {
+		case "time":
+			t, err := time.Parse(time.RFC3339Nano, p.value)
+			if err != nil {
+				return Entry{}, ErrFormat
+			}
+			e.Time = t.In(loc)
+			hasTime = true
+		case "level":
+			level, err := ParseLevel(p.value)
+			if err != nil {
+				return Entry{}, ErrFormat
+			}
+			e.Level = level
+			hasLevel = true
+		case "caller":
+			e.Caller = p.value
+		case "msg":
+			e.Message = p.value
+		default:
+			e.Attrs = append(e.Attrs, slog.String(p.key, p.value))
+		}
+	}
+	if !hasTime || !hasLevel {
+		return Entry{}, ErrFormat
+	}
+	return e, nil
+}
+
+// quoteValue quotes a value the same way the handlers do.
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for _, c := range s {
+		if c == '=' || c == '"' || c == '|' || unicode.IsSpace(c) || !unicode.IsPrint(c) {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,296 @@
+// Package logparse reads back the log files written by the extended-log-go handlers.
+//
+// All three output formats are understood and detected line by line:
+//
+//	text:   dd.mm.yyyy hh:mm:ss.mmm | LEVEL | [file:line] message | key=value ...
+//	json:   {"time":"...","level":"INFO","caller":"file:line","msg":"...","key":"value"}
+//	logfmt: time=... level=INFO caller=file:line msg="..." key=value
+//
+// The caller and attribute parts are optional. Lines of the text format that
+// do not start with a timestamp continue the message of the previous entry.
+package logparse
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// TimeLayout is the timestamp layout used by the text format.
+const TimeLayout = "02.01.2006 15:04:05.000"
+
+// LevelTrace mirrors log.LevelTrace without importing the log package,
+// whose initialization has side effects.
+const LevelTrace = slog.Level(-8)
+
+// ErrFormat is returned for lines that do not follow any of the output formats.
+var ErrFormat = errors.New("logparse: line does not match log format")
+
+// Entry is a single parsed log record.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Caller  string
+	Message string
+	Attrs   []slog.Attr
+}
+
+// String formats the entry in the text format, indenting continuation lines
+// of multi-line messages with ContinuationMarker.
+func (e Entry) String() string {
+	var b strings.Builder
+	b.WriteString(e.Time.Format(TimeLayout))
+	b.WriteString(" | ")
+	b.WriteString(fmt.Sprintf("%-5s", LevelString(e.Level)))
+	b.WriteString(" | ")
+	if e.Caller != "" {
+		b.WriteString("[" + e.Caller + "] ")
+	}
+	b.WriteString(strings.ReplaceAll(escapeSeparator(e.Message), "\n", "\n"+ContinuationMarker))
+	if len(e.Attrs) > 0 {
+		b.WriteString(" |")
+		for _, a := range e.Attrs {
+			b.WriteByte(' ')
+			b.WriteString(a.Key)
+			b.WriteByte('=')
+			b.WriteString(quoteValue(a.Value.String()))
+		}
+	}
+	return b.String()
+}
+
+// Attr returns the value of the attribute with the given key.
+func (e Entry) Attr(key string) (slog.Value, bool) {
+	for _, a := range e.Attrs {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return slog.Value{}, false
+}
+
+// LevelString returns the level name as written by the handlers.
+func LevelString(level slog.Level) string {
+	switch {
+	case level < slog.LevelDebug:
+		return "TRACE"
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// ParseLevel converts a level name (case-insensitive) to a slog.Level.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "TRACE":
+		return LevelTrace, nil
+	case "DEBUG":
+		return slog.LevelDebug, nil
+	case "INFO":
+		return slog.LevelInfo, nil
+	case "WARN", "WARNING":
+		return slog.LevelWarn, nil
+	case "ERROR", "FATAL":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("logparse: unknown level %q", s)
+}
+
+// DefaultLocation returns the time zone configured by LOG_TIMEZONE, which is
+// the zone text timestamps are written in, or the local time zone.
+func DefaultLocation() *time.Location {
+	if name := os.Getenv("LOG_TIMEZONE"); name != "" {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
+
+// ParseLine parses a single line in any of the output formats. Text
+// timestamps are interpreted in loc; all times are returned in loc.
+func ParseLine(line string, loc *time.Location) (Entry, error) {
+	line = strings.TrimRight(line, "\r\n")
+	e, text, err := parseHeader(line, loc)
+	if err != nil {
+		return Entry{}, err
+	}
+	if text {
+		finishText(&e, nil)
+	}
+	return e, nil
+}
+
+// parseHeader parses the first line of an entry. For the text format the
+// message is returned raw, since continuation lines may follow.
+func parseHeader(line string, loc *time.Location) (e Entry, text bool, err error) {
+	switch {
+	case strings.HasPrefix(line, "{"):
+		e, err = parseJSON(line, loc)
+	case strings.HasPrefix(line, "time="):
+		e, err = parseLogfmt(line, loc)
+	default:
+		e, err = parseTextHeader(line, loc)
+		text = true
+	}
+	return e, text, err
+}
+
+// Scanner reads entries from a stream, joining multi-line messages and
+// skipping lines that cannot be parsed.
+//
+// After Scan returns false at the end of the input with a nil Err, it may be
+// called again to pick up data appended to the stream later, which allows
+// following a file that is still being written. See Follow.
+type Scanner struct {
+	r      *bufio.Reader
+	loc    *time.Location
+	entry  Entry
+	err    error
+	follow bool
+	tail   string // last line read without its newline while following
+
+	pending *Entry   // entry waiting for possible continuation lines
+	lines   []string // continuation lines of a pending text entry
+	text    bool     // whether the pending entry uses the text format
+	ready   []Entry  // complete entries not yet returned
+}
+
+// NewScanner returns a Scanner reading from r with times in loc.
+func NewScanner(r io.Reader, loc *time.Location) *Scanner {
+	if loc == nil {
+		loc = DefaultLocation()
+	}
+	return &Scanner{r: bufio.NewReaderSize(r, 64*1024), loc: loc}
+}
+
+// Scan advances to the next entry, returning false at the end of input or on error.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	for {
+		if len(s.ready) > 0 {
+			s.entry = s.ready[0]
+			s.ready = s.ready[1:]
+			return true
+		}
+
+		line, err := s.r.ReadString('\n')
+		if err == io.EOF && s.follow {
+			// The rest of the line has not been written yet
+			s.tail += line
+			line = ""
+		} else if s.tail != "" {
+			line, s.tail = s.tail+line, ""
+		}
+		if line != "" {
+			s.consume(strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			// An entry can't continue past the end of the available input
+			s.flush()
+			if len(s.ready) == 0 {
+				return false
+			}
+		}
+	}
+}
+
+// Follow sets whether the input is a file that is still being written. While
+// following, a last line without a newline is kept until the rest of it has
+// been read, instead of being taken as a complete line. Stop following to
+// read a kept line once the file is complete.
+func (s *Scanner) Follow(follow bool) {
+	s.follow = follow
+}
+
+// consume processes a single line.
+func (s *Scanner) consume(line string) {
+	e, text, err := parseHeader(line, s.loc)
+	if err != nil {
+		if s.pending != nil && s.text {
+			s.lines = append(s.lines, line)
+		}
+		return
+	}
+	s.flush()
+	s.pending = &e
+	s.text = text
+}
+
+// flush completes the pending entry.
+func (s *Scanner) flush() {
+	if s.pending == nil {
+		return
+	}
+	e := *s.pending
+	if s.text {
+		finishText(&e, s.lines)
+	}
+	s.ready = append(s.ready, e)
+	s.pending = nil
+	s.lines = nil
+}
+
+// Entry returns the most recent entry read by Scan.
+func (s *Scanner) Entry() Entry {
+	return s.entry
+}
+
+// Err returns the first non-EOF error encountered by the Scanner.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Entries returns an iterator over the entries read from r. Entries are
+// parsed lazily, so arbitrarily large files can be processed. A read error
+// is yielded once as the final element.
+func Entries(r io.Reader, loc *time.Location) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		s := NewScanner(r, loc)
+		for s.Scan() {
+			if !yield(s.Entry(), nil) {
+				return
+			}
+		}
+		if err := s.Err(); err != nil {
+			yield(Entry{}, err)
+		}
+	}
+}
+
+// ReadFile returns an iterator over the entries of a plain log file.
+func ReadFile(name string, loc *time.Location) iter.Seq2[Entry, error] {
+	return func(yield func(Entry, error) bool) {
+		file, err := os.Open(name)
+		if err != nil {
+			yield(Entry{}, err)
+			return
+		}
+		defer func(file *os.File) {
+			_ = file.Close()
+		}(file)
+
+		for e, err := range Entries(file, loc) {
+			if !yield(e, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package logparse
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line    string
+		level   slog.Level
+		caller  string
+		message string
+	}{
+		{"18.11.2025 11:04:17.250 | INFO  | Application started", slog.LevelInfo, "", "Application started"},
+		{"18.11.2025 11:04:17.251 | WARN  | [main.go:30] Database connection slow", slog.LevelWarn, "main.go:30", "Database connection slow"},
+		{"18.11.2025 11:04:17.252 | TRACE | [handlers.go:7] a | b", LevelTrace, "handlers.go:7", "a | b"},
+		{"18.11.2025 11:04:17.253 | ERROR | [not a caller] boom", slog.LevelError, "", "[not a caller] boom"},
+		{"18.11.2025 11:04:17.254 | \x1b[36mINFO \x1b[0m | colored", slog.LevelInfo, "", "colored"},
+		{"18.11.2025 11:04:17.255 | DEBUG | ", slog.LevelDebug, "", ""},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseLine(tt.line, time.UTC)
+		if err != nil {
+			t.Errorf("ParseLine(%q) error: %v", tt.line, err)
+			continue
+		}
+		if e.Level != tt.level || e.Caller != tt.caller || e.Message != tt.message {
+			t.Errorf("ParseLine(%q) = {%v %q %q}, want {%v %q %q}", tt.line, e.Level, e.Caller, e.Message, tt.level, tt.caller, tt.message)
+		}
+	}
+}
+
+func TestParseLine_Time(t *testing.T) {
+	loc := time.FixedZone("UTC+4", 4*60*60)
+	e, err := ParseLine("18.11.2025 11:04:17.250 | INFO  | x", loc)
+	if err != nil {
+		t.Fatalf("ParseLine() error: %v", err)
+	}
+	want := time.Date(2025, 11, 18, 11, 4, 17, 250e6, loc)
+	if !e.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", e.Time, want)
+	}
+}
+
+func TestParseLine_Invalid(t *testing.T) {
+	for _, line := range []string{"", "garbage", "18.11.2025 | INFO | x", "18.11.2025 11:04:17.250 | LOUD  | x"} {
+		if _, err := ParseLine(line, time.UTC); err == nil {
+			t.Errorf("ParseLine(%q) expected error", line)
+		}
+	}
+}
+
+func TestEntry_StringRoundTrip(t *testing.T) {
+	line := "18.11.2025 11:04:17.250 | WARN  | [main.go:30] slow"
+	e, err := ParseLine(line, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseLine() error: %v", err)
+	}
+	if got := e.String(); got != line {
+		t.Errorf("String() = %q, want %q", got, line)
+	}
+}
+
+func TestParseLine_TextAttrs(t *testing.T) {
+	e, err := ParseLine(`18.11.2025 11:04:17.250 | INFO  | [main.go:3] request done | status=200 path=/api user="John Doe"`, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseLine() error: %v", err)
+	}
+	if e.Message != "request done" || e.Caller != "main.go:3" {
+		t.Errorf("got message %q caller %q", e.Message, e.Caller)
+	}
+	if v, ok := e.Attr("user"); !ok || v.String() != "John Doe" {
+		t.Errorf("user attr = %v, %v", v, ok)
+	}
+	if len(e.Attrs) != 3 {
+		t.Errorf("expected 3 attrs, got %v", e.Attrs)
+	}
+}
+
+func TestParseLine_TextPipeInMessage(t *testing.T) {
+	e, err := ParseLine("18.11.2025 11:04:17.250 | INFO  | a | b c", time.UTC)
+	if err != nil {
+		t.Fatalf("ParseLine() error: %v", err)
+	}
+	if e.Message != "a | b c" || len(e.Attrs) != 0 {
+		t.Errorf("got message %q attrs %v", e.Message, e.Attrs)
+	}
+}
+
+func TestParseLine_JSON(t *testing.T) {
+	loc := time.FixedZone("UTC+4", 4*60*60)
+	e, err := ParseLine(`{"time":"2025-11-18T07:04:17.250Z","level":"TRACE","caller":"a.go:1","msg":"hi","n":3,"ok":true,"s":"x"}`, loc)
+	if err != nil {
+		t.Fatalf("ParseLine() error: %v", err)
+	}
+	if e.Level != LevelTrace || e.Caller != "a.go:1" || e.Message != "hi" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e.Time.Location() != loc || e.Time.Hour() != 11 {
+		t.Errorf("time not converted to location: %v", e.Time)
+	}
+	if v, _ := e.Attr("n"); v.Int64() != 3 {
+		t.Errorf("n = %v, want 3", v)
+	}
+	if v, _ := e.Attr("ok"); !v.Bool() {
+		t.Errorf("ok = %v, want true", v)
+	}
+	if len(e.Attrs) != 3 || e.Attrs[0].Key != "n" || e.Attrs[2].Key != "s" {
+		t.Errorf("attrs out of order: %v", e.Attrs)
+	}
+}
+
+func TestParseLine_Logfmt(t *testing.T) {
+	e, err := ParseLine(`time=2025-11-18T11:04:17.250+04:00 level=WARN msg="disk \"almost\" full" free=5%`, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseLine() error: %v", err)
+	}
+	if e.Level != slog.LevelWarn || e.Message != `disk "almost" full` {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if v, _ := e.Attr("free"); v.String() != "5%" {
+		t.Errorf("free = %v", v)
+	}
+	if e.Time.Hour() != 7 {
+		t.Errorf("time = %v, want 07:04 UTC", e.Time)
+	}
+}
+
+func TestScanner_MultiLine(t *testing.T) {
+	input := strings.Join([]string{
+		"garbage before the first entry",
+		"18.11.2025 11:04:17.250 | INFO  | ",
+		"=== Trace Level ===",
+		"18.11.2025 11:04:17.251 | ERROR | panic: boom",
+		"goroutine 1 [running]:",
+		"main.main() | n=1",
+		`{"time":"2025-11-18T11:04:17.252Z","level":"INFO","msg":"json"}`,
+	}, "\n")
+
+	s := NewScanner(strings.NewReader(input), time.UTC)
+	var entries []Entry
+	for s.Scan() {
+		entries = append(entries, s.Entry())
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Message != "\n=== Trace Level ===" {
+		t.Errorf("entry 0 message = %q", entries[0].Message)
+	}
+	if entries[1].Message != "panic: boom\ngoroutine 1 [running]:\nmain.main()" {
+		t.Errorf("entry 1 message = %q", entries[1].Message)
+	}
+	if v, ok := entries[1].Attr("n"); !ok || v.String() != "1" {
+		t.Errorf("entry 1 attrs = %v", entries[1].Attrs)
+	}
+	if entries[2].Message != "json" {
+		t.Errorf("entry 2 message = %q", entries[2].Message)
+	}
+}
+
+func TestScanner_Resume(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewScanner(&buf, time.UTC)
+
+	buf.WriteString("18.11.2025 11:04:17.250 | INFO  | one\n")
+	if !s.Scan() || s.Entry().Message != "one" {
+		t.Fatalf("expected first entry, got %+v", s.Entry())
+	}
+	if s.Scan() {
+		t.Fatal("expected end of input")
+	}
+
+	buf.WriteString("18.11.2025 11:04:17.251 | INFO  | two\n")
+	if !s.Scan() || s.Entry().Message != "two" {
+		t.Fatalf("expected appended entry, got %+v", s.Entry())
+	}
+}
+
+func TestScanner_FollowPartialLine(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewScanner(&buf, time.UTC)
+	s.Follow(true)
+
+	buf.WriteString("18.11.2025 11:04:17.250 | INFO  | one\n18.11.2025 11:04:17.251 | INFO  | tw")
+	if !s.Scan() || s.Entry().Message != "one" {
+		t.Fatalf("expected first entry, got %+v", s.Entry())
+	}
+	if s.Scan() {
+		t.Fatalf("a partially written line should wait for the rest, got %+v", s.Entry())
+	}
+
+	buf.WriteString("o | k=v\n18.11.2025 11:04:17.252 | INFO  | thr")
+	if !s.Scan() || s.Entry().Message != "two" || len(s.Entry().Attrs) != 1 {
+		t.Fatalf("expected the completed entry, got %+v", s.Entry())
+	}
+	if s.Scan() {
+		t.Fatalf("unexpected entry %+v", s.Entry())
+	}
+
+	// Once the file is complete, a last line without a newline is read
+	s.Follow(false)
+	if !s.Scan() || s.Entry().Message != "thr" {
+		t.Fatalf("expected the last line, got %+v", s.Entry())
+	}
+}
+
+func TestEntries(t *testing.T) {
+	input := "18.11.2025 11:04:17.250 | INFO  | one\n18.11.2025 11:04:17.251 | INFO  | two\n18.11.2025 11:04:17.252 | INFO  | three\n"
+
+	var messages []string
+	for e, err := range Entries(strings.NewReader(input), time.UTC) {
+		if err != nil {
+			t.Fatalf("Entries() error: %v", err)
+		}
+		messages = append(messages, e.Message)
+		if len(messages) == 2 {
+			break
+		}
+	}
+	if strings.Join(messages, ",") != "one,two" {
+		t.Errorf("messages = %v, want [one two]", messages)
+	}
+}
+
+func TestScanner_ContinuationMarker(t *testing.T) {
+	input := "18.11.2025 11:04:17.250 | ERROR | panic: boom\n  > goroutine 1\n  > 18.11.2025 11:04:17.251 | INFO  | forged | k=v\n"
+
+	var entries []Entry
+	for e, err := range Entries(strings.NewReader(input), time.UTC) {
+		if err != nil {
+			t.Fatalf("Entries() error: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d: %+v", len(entries), entries)
+	}
+	if want := "panic: boom\ngoroutine 1\n18.11.2025 11:04:17.251 | INFO  | forged"; entries[0].Message != want {
+		t.Errorf("message = %q, want %q", entries[0].Message, want)
+	}
+	// String escapes the separators in the message the way the writer does
+	if got, want := entries[0].String(), "18.11.2025 11:04:17.250 | ERROR | panic: boom\n  > goroutine 1\n  > 18.11.2025 11:04:17.251 \\| INFO  \\| forged | k=v"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestParseLine_Quoted(t *testing.T) {
+	e, err := ParseLine(`18.11.2025 11:04:17.250 | INFO  | [a.go:1] "two\nlines | x=y" | k=v`, time.UTC)
+	if err != nil {
+		t.Fatalf("ParseLine() error: %v", err)
+	}
+	if e.Message != "two\nlines | x=y" || e.Caller != "a.go:1" {
+		t.Errorf("got message %q caller %q", e.Message, e.Caller)
+	}
+	if len(e.Attrs) != 1 || e.Attrs[0].Key != "k" {
+		t.Errorf("attrs = %v", e.Attrs)
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := map[string]string{
+		`plain`:           "plain",
+		`a\nb`:            "a\nb",
+		`C:\\temp`:        `C:\temp`,
+		`bell\x07 \u202e`: "bell\x07 \u202e",
+		`bad \q escape`:   `bad \q escape`,
+	}
+	for in, want := range tests {
+		if got := Unescape(in); got != want {
+			t.Errorf("Unescape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
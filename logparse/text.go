@@ -0,0 +1,157 @@
+package logparse
+
+import (
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContinuationMarker starts the continuation lines of multi-line messages
+// written with the indent policy (the default).
+const ContinuationMarker = "  > "
+
+var callerPattern = regexp.MustCompile(`^\[([^\]\s]+:\d+)\] ?`)
+
+// ansiPattern matches the color codes used by the console handler.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// parseTextHeader parses "timestamp | LEVEL | rest". The rest is stored in
+// Message unprocessed.
+func parseTextHeader(line string, loc *time.Location) (Entry, error) {
+	parts := strings.SplitN(line, " | ", 3)
+	if len(parts) < 2 {
+		return Entry{}, ErrFormat
+	}
+
+	ts, err := time.ParseInLocation(TimeLayout, parts[0], loc)
+	if err != nil {
+		return Entry{}, ErrFormat
+	}
+
+	// The message is empty when the line ends right after the level
+	levelText := ansiPattern.ReplaceAllString(strings.TrimSuffix(parts[1], " |"), "")
+	level, err := ParseLevel(levelText)
+	if err != nil {
+		return Entry{}, ErrFormat
+	}
+
+	e := Entry{Time: ts, Level: level}
+	if len(parts) == 3 {
+		e.Message = parts[2]
+	}
+	return e, nil
+}
+
+// finishText joins continuation lines into the message and splits off the
+// caller prefix and the trailing attributes.
+func finishText(e *Entry, lines []string) {
+	msg := e.Message
+	for _, line := range lines {
+		msg += "\n" + strings.TrimPrefix(line, ContinuationMarker)
+	}
+
+	if m := callerPattern.FindStringSubmatch(msg); m != nil {
+		e.Caller = m[1]
+		msg = msg[len(m[0]):]
+	}
+
+	// Messages written with the quote policy are Go string literals
+	if strings.HasPrefix(msg, `"`) {
+		if quoted, err := strconv.QuotedPrefix(msg); err == nil {
+			rest := msg[len(quoted):]
+			if rest == "" || strings.HasPrefix(rest, " | ") {
+				e.Message, _ = strconv.Unquote(quoted)
+				e.Attrs = parseTextAttrs(strings.TrimPrefix(rest, " | "))
+				return
+			}
+		}
+	}
+
+	// Attributes follow the last " | " separator as key=value pairs
+	if i := strings.LastIndex(msg, "| "); i >= 0 && (i == 0 || msg[i-1] == ' ') {
+		if attrs := parseTextAttrs(msg[i+2:]); len(attrs) > 0 {
+			e.Attrs = attrs
+			msg = strings.TrimSuffix(msg[:i], " ")
+		}
+	}
+	e.Message = unescapeSeparator(msg)
+}
+
+// escapeSeparator escapes "| " at the start of a message or after a space as
+// "\| ", the way the text format writes messages, so that the rest of the
+// message is not taken for attributes.
+func escapeSeparator(msg string) string {
+	if !strings.Contains(msg, "| ") {
+		return msg
+	}
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if strings.HasPrefix(msg[i:], "| ") && (i == 0 || msg[i-1] == ' ') {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
+
+// unescapeSeparator reverses escapeSeparator.
+func unescapeSeparator(msg string) string {
+	if !strings.Contains(msg, `\| `) {
+		return msg
+	}
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if strings.HasPrefix(msg[i:], `\| `) && (i == 0 || msg[i-1] == ' ') {
+			continue
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
+
+// parseTextAttrs parses the key=value pairs following the message, returning
+// nil if s is not a valid list of pairs.
+func parseTextAttrs(s string) []slog.Attr {
+	pairs, err := parsePairs(s)
+	if err != nil || len(pairs) == 0 {
+		return nil
+	}
+	attrs := make([]slog.Attr, 0, len(pairs))
+	for _, p := range pairs {
+		attrs = append(attrs, slog.String(p.key, p.value))
+	}
+	return attrs
+}
+
+// Unescape reverses the escaping applied to messages by the escape policy
+// (LOG_MESSAGE_POLICY=escape). Invalid escape sequences are kept as is.
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for len(s) > 0 {
+		if s[0] != '\\' {
+			b.WriteByte(s[0])
+			s = s[1:]
+			continue
+		}
+		value, multibyte, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			b.WriteByte(s[0])
+			s = s[1:]
+			continue
+		}
+		if value < utf8.RuneSelf || multibyte {
+			b.WriteRune(value)
+		} else {
+			b.WriteByte(byte(value))
+		}
+		s = tail
+	}
+	return b.String()
+}
@@ -0,0 +1,291 @@
+// Package logtest records log output in tests and provides assertions on it.
+//
+// Tests that log through the package-level functions of the log package use
+// CaptureGlobal, which temporarily replaces the global logger:
+//
+//	func TestImport(t *testing.T) {
+//		rec := logtest.CaptureGlobal(t)
+//		runImport()
+//		rec.HasEntry(slog.LevelInfo, "import finished", "rows", 42)
+//		rec.NoErrors()
+//	}
+//
+// CaptureGlobal swaps state shared by the whole test binary, so it is only
+// for tests that do not call t.Parallel: records logged by tests running at
+// the same time would end up in the wrong Recorder. Parallel tests pass the
+// per-test logger returned by New(t).Logger() to the code under test instead.
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tsisar/extended-log-go/log"
+	"github.com/tsisar/extended-log-go/logparse"
+)
+
+// Entry is a recorded log record. Attributes are flattened the same way the
+// log handlers write them, with groups as dotted keys.
+type Entry = logparse.Entry
+
+// Recorder is a slog.Handler that keeps every record it receives, at every level.
+type Recorder struct {
+	t     testing.TB
+	store *store
+	attrs []slog.Attr
+	group string
+}
+
+// store is shared between a Recorder and the handlers derived from it.
+type store struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// New returns a Recorder whose assertions report to t.
+func New(t testing.TB) *Recorder {
+	return &Recorder{t: t, store: &store{}}
+}
+
+// CaptureGlobal installs a new Recorder as the logger of the log package and
+// restores the previous logger when the test finishes. It is a global swap
+// for serial tests only; see the package documentation.
+func CaptureGlobal(t testing.TB) *Recorder {
+	t.Helper()
+	r := New(t)
+	prev := log.Logger()
+	log.SetLogger(r.Logger())
+	t.Cleanup(func() {
+		log.SetLogger(prev)
+	})
+	return r
+}
+
+// Logger returns a logger writing to the Recorder.
+func (r *Recorder) Logger() *slog.Logger {
+	return slog.New(r)
+}
+
+// Enabled reports true for every level so that all records are captured.
+func (r *Recorder) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle records r.
+func (r *Recorder) Handle(_ context.Context, rec slog.Record) error {
+	e := Entry{
+		Time:    rec.Time,
+		Level:   rec.Level,
+		Caller:  caller(rec.PC),
+		Message: rec.Message,
+	}
+	e.Attrs = append(e.Attrs, r.attrs...)
+	rec.Attrs(func(a slog.Attr) bool {
+		e.Attrs = appendAttr(e.Attrs, r.group, a)
+		return true
+	})
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.entries = append(r.store.entries, e)
+	return nil
+}
+
+// WithAttrs returns a handler recording into the same Recorder with attrs added.
+func (r *Recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	r2 := *r
+	r2.attrs = append([]slog.Attr(nil), r.attrs...)
+	for _, a := range attrs {
+		r2.attrs = appendAttr(r2.attrs, r.group, a)
+	}
+	return &r2
+}
+
+// WithGroup returns a handler recording into the same Recorder with keys
+// prefixed by name.
+func (r *Recorder) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return r
+	}
+	r2 := *r
+	r2.group = r.group + name + "."
+	return &r2
+}
+
+// Entries returns a copy of all recorded entries in order.
+func (r *Recorder) Entries() []Entry {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return append([]Entry(nil), r.store.entries...)
+}
+
+// Reset discards all recorded entries.
+func (r *Recorder) Reset() {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.entries = nil
+}
+
+// HasEntry reports whether an entry matching level, message substring and
+// attributes was recorded, and fails the test if not. Attributes are given
+// as alternating keys and values or as slog.Attr, like in slog.Logger.Info.
+func (r *Recorder) HasEntry(level slog.Level, msgSubstring string, attrs ...any) bool {
+	r.t.Helper()
+	m := Match(level, msgSubstring, attrs...)
+	for _, e := range r.Entries() {
+		if m.Matches(e) {
+			return true
+		}
+	}
+	r.t.Errorf("logtest: no entry matching %s\n%s", m, r.dump())
+	return false
+}
+
+// NoErrors fails the test if any entry at Error level or above was recorded.
+func (r *Recorder) NoErrors() bool {
+	r.t.Helper()
+	var errs []string
+	for _, e := range r.Entries() {
+		if e.Level >= slog.LevelError {
+			errs = append(errs, "\t"+e.String())
+		}
+	}
+	if len(errs) > 0 {
+		r.t.Errorf("logtest: unexpected error entries:\n%s", strings.Join(errs, "\n"))
+		return false
+	}
+	return true
+}
+
+// InOrder reports whether entries matching the given matchers were recorded
+// in this order, possibly with other entries in between, and fails the test if not.
+func (r *Recorder) InOrder(matchers ...Matcher) bool {
+	r.t.Helper()
+	i := 0
+	for _, e := range r.Entries() {
+		if i < len(matchers) && matchers[i].Matches(e) {
+			i++
+		}
+	}
+	if i < len(matchers) {
+		r.t.Errorf("logtest: entries not found in order, first missing: %s\n%s", matchers[i], r.dump())
+		return false
+	}
+	return true
+}
+
+// dump formats the recorded entries for failure messages.
+func (r *Recorder) dump() string {
+	entries := r.Entries()
+	if len(entries) == 0 {
+		return "recorded entries: none"
+	}
+	lines := make([]string, 0, len(entries)+1)
+	lines = append(lines, "recorded entries:")
+	for _, e := range entries {
+		lines = append(lines, "\t"+e.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Matcher describes an expected entry.
+type Matcher struct {
+	Level   slog.Level
+	Message string
+	Attrs   []slog.Attr
+}
+
+// Match returns a Matcher for an entry with the given level, message
+// substring and attributes.
+func Match(level slog.Level, msgSubstring string, attrs ...any) Matcher {
+	return Matcher{Level: level, Message: msgSubstring, Attrs: argsToAttrs(attrs)}
+}
+
+// Matches reports whether e satisfies the matcher.
+func (m Matcher) Matches(e Entry) bool {
+	if e.Level != m.Level || !strings.Contains(e.Message, m.Message) {
+		return false
+	}
+	for _, want := range m.Attrs {
+		got, ok := e.Attr(want.Key)
+		if !ok || !valuesEqual(got, want.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Matcher) String() string {
+	s := fmt.Sprintf("level=%s msg~%q", logparse.LevelString(m.Level), m.Message)
+	for _, a := range m.Attrs {
+		s += fmt.Sprintf(" %s=%v", a.Key, a.Value)
+	}
+	return s
+}
+
+// valuesEqual compares attribute values, treating values with the same
+// string form as equal so that int and int64 or errors and strings match.
+func valuesEqual(got, want slog.Value) bool {
+	return got.Equal(want) || got.String() == want.String()
+}
+
+// argsToAttrs converts slog-style arguments to attributes.
+func argsToAttrs(args []any) []slog.Attr {
+	var attrs []slog.Attr
+	for len(args) > 0 {
+		switch a := args[0].(type) {
+		case slog.Attr:
+			attrs = appendAttr(attrs, "", a)
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				attrs = append(attrs, slog.String("!BADKEY", a))
+				args = nil
+				continue
+			}
+			attrs = appendAttr(attrs, "", slog.Any(a, args[1]))
+			args = args[2:]
+		default:
+			attrs = append(attrs, slog.Any("!BADKEY", a))
+			args = args[1:]
+		}
+	}
+	return attrs
+}
+
+// appendAttr resolves a and flattens groups into dotted keys.
+func appendAttr(attrs []slog.Attr, prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	a.Key = prefix + a.Key
+	return append(attrs, a)
+}
+
+// caller returns "file.go:line" for a program counter.
+func caller(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if f.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(f.File), f.Line)
+}
@@ -0,0 +1,136 @@
+package logtest
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tsisar/extended-log-go/log"
+)
+
+// fakeT records failures and log output instead of reporting them to the
+// surrounding test.
+type fakeT struct {
+	testing.TB
+	failures []string
+	logs     []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...any) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeT) Log(args ...any) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func TestCaptureGlobal_RecordsGlobalLogger(t *testing.T) {
+	rec := CaptureGlobal(t)
+
+	log.Trace("starting import")
+	log.Infof("imported %d rows", 42)
+
+	rec.HasEntry(log.LevelTrace, "starting import")
+	rec.HasEntry(slog.LevelInfo, "42 rows")
+	rec.NoErrors()
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if !strings.HasPrefix(entries[0].Caller, "logtest_test.go:") {
+		t.Errorf("caller = %q, want logtest_test.go", entries[0].Caller)
+	}
+}
+
+func TestCaptureGlobal_RestoresLogger(t *testing.T) {
+	orig := log.Logger()
+	t.Run("inner", func(t *testing.T) {
+		CaptureGlobal(t)
+		if log.Logger() == orig {
+			t.Error("CaptureGlobal did not install the recorder")
+		}
+	})
+	if log.Logger() != orig {
+		t.Error("CaptureGlobal did not restore the previous logger")
+	}
+}
+
+func TestRecorder_Attrs(t *testing.T) {
+	t.Parallel()
+	rec := New(t)
+	l := rec.Logger().With("service", "billing").WithGroup("req")
+
+	l.Warn("slow request", "status", 200, slog.Group("db", slog.Int("queries", 3)))
+
+	rec.HasEntry(slog.LevelWarn, "slow", "service", "billing", "req.status", 200, "req.db.queries", 3)
+	rec.HasEntry(slog.LevelWarn, "slow", slog.Int64("req.status", 200))
+}
+
+func TestRecorder_HasEntryFailure(t *testing.T) {
+	t.Parallel()
+	ft := &fakeT{TB: t}
+	rec := New(ft)
+	rec.Logger().Info("hello", "user", "alice")
+
+	if rec.HasEntry(slog.LevelInfo, "hello", "user", "bob") {
+		t.Error("HasEntry should not match a different attribute value")
+	}
+	if rec.HasEntry(slog.LevelError, "hello") {
+		t.Error("HasEntry should not match a different level")
+	}
+	if len(ft.failures) != 2 {
+		t.Fatalf("expected 2 failures, got %v", ft.failures)
+	}
+	if !strings.Contains(ft.failures[0], "hello") || !strings.Contains(ft.failures[0], "user=alice") {
+		t.Errorf("failure should list the recorded entries, got %q", ft.failures[0])
+	}
+}
+
+func TestRecorder_NoErrors(t *testing.T) {
+	t.Parallel()
+	ft := &fakeT{TB: t}
+	rec := New(ft)
+	rec.Logger().Error("database unavailable", "err", errors.New("refused"))
+
+	if rec.NoErrors() {
+		t.Error("NoErrors should fail when an error was logged")
+	}
+	if len(ft.failures) != 1 || !strings.Contains(ft.failures[0], "database unavailable") {
+		t.Errorf("unexpected failures: %v", ft.failures)
+	}
+}
+
+func TestRecorder_InOrder(t *testing.T) {
+	t.Parallel()
+	ft := &fakeT{TB: t}
+	rec := New(ft)
+	l := rec.Logger()
+	l.Info("connecting")
+	l.Debug("retry", "attempt", 1)
+	l.Info("connected")
+
+	if !rec.InOrder(Match(slog.LevelInfo, "connecting"), Match(slog.LevelInfo, "connected")) {
+		t.Error("InOrder should match entries with others in between")
+	}
+	if rec.InOrder(Match(slog.LevelInfo, "connected"), Match(slog.LevelInfo, "connecting")) {
+		t.Error("InOrder should fail for reversed order")
+	}
+	if len(ft.failures) != 1 {
+		t.Errorf("expected 1 failure, got %v", ft.failures)
+	}
+}
+
+func TestRecorder_Reset(t *testing.T) {
+	t.Parallel()
+	rec := New(t)
+	rec.Logger().Info("before")
+	rec.Reset()
+	if n := len(rec.Entries()); n != 0 {
+		t.Errorf("expected no entries after Reset, got %d", n)
+	}
+}
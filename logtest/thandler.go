@@ -0,0 +1,137 @@
+package logtest
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tsisar/extended-log-go/log"
+)
+
+// THandler is a slog.Handler that writes records to the output of a test
+// with t.Log, formatted like the console output and always including the
+// caller. Records at Error level or above fail the test unless allowed.
+//
+// The record's caller is the [file:line] in the message. The file:line
+// prefix that go test -v adds to every t.Log line points into this package
+// instead, since the testing package cannot skip the frames of slog.
+type THandler struct {
+	t     testing.TB
+	level slog.Leveler
+	state *tState
+	attrs []slog.Attr
+	group string
+}
+
+// tState is shared between a THandler and the handlers derived from it.
+type tState struct {
+	mu          sync.Mutex
+	done        bool
+	allowAll    bool
+	allowErrors []string
+}
+
+// NewTHandler returns a handler logging to t at level and above. A nil level
+// uses the level of the log package (LOG_LEVEL).
+func NewTHandler(t testing.TB, level slog.Leveler) *THandler {
+	if level == nil {
+		level = log.Level()
+	}
+	h := &THandler{t: t, level: level, state: &tState{}}
+	// Logging after the test has finished panics, so late records are dropped
+	t.Cleanup(func() {
+		h.state.mu.Lock()
+		defer h.state.mu.Unlock()
+		h.state.done = true
+	})
+	return h
+}
+
+// TLogger returns a logger writing to the output of t. It is safe to use in
+// parallel tests, since every test gets its own logger.
+func TLogger(t testing.TB, level slog.Leveler) *slog.Logger {
+	return slog.New(NewTHandler(t, level))
+}
+
+// AllowErrors stops error records whose message contains one of the given
+// substrings from failing the test. Without arguments all errors are allowed.
+func (h *THandler) AllowErrors(substrings ...string) *THandler {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	if len(substrings) == 0 {
+		h.state.allowAll = true
+	}
+	h.state.allowErrors = append(h.state.allowErrors, substrings...)
+	return h
+}
+
+// Enabled reports whether level is at or above the handler's level.
+func (h *THandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle writes the record to the test output, failing the test for
+// unexpected errors.
+func (h *THandler) Handle(_ context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(h.attrs...)
+	r.Attrs(func(a slog.Attr) bool {
+		if h.group != "" && a.Key != "" {
+			a.Key = h.group + a.Key
+		}
+		nr.AddAttrs(a)
+		return true
+	})
+	line := log.FormatText(nr, true)
+
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	if h.state.done {
+		return nil
+	}
+
+	if r.Level >= slog.LevelError && !h.allowed(r.Message) {
+		h.t.Errorf("unexpected error logged: %s", line)
+		return nil
+	}
+	h.t.Log(line)
+	return nil
+}
+
+// allowed reports whether an error message was allowed by AllowErrors.
+func (h *THandler) allowed(msg string) bool {
+	if h.state.allowAll {
+		return true
+	}
+	for _, s := range h.state.allowErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithAttrs returns a handler adding attrs to every record.
+func (h *THandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		if h.group != "" && a.Key != "" {
+			a.Key = h.group + a.Key
+		}
+		h2.attrs = append(h2.attrs, a)
+	}
+	return &h2
+}
+
+// WithGroup returns a handler prefixing attribute keys with name.
+func (h *THandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
@@ -0,0 +1,88 @@
+package logtest
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestTHandler_WritesToTestOutput(t *testing.T) {
+	t.Parallel()
+	ft := &fakeT{TB: t}
+	l := TLogger(ft, slog.LevelInfo).With("service", "billing").WithGroup("req")
+
+	l.Debug("hidden")
+	l.Info("request done", "status", 200)
+
+	if len(ft.logs) != 1 {
+		t.Fatalf("expected 1 line, got %v", ft.logs)
+	}
+	line := ft.logs[0]
+	for _, want := range []string{"| INFO  |", "[thandler_test.go:", "request done", "service=billing", "req.status=200"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("line %q should contain %q", line, want)
+		}
+	}
+	if len(ft.failures) != 0 {
+		t.Errorf("unexpected failures: %v", ft.failures)
+	}
+}
+
+func TestTHandler_FailsOnErrors(t *testing.T) {
+	t.Parallel()
+	ft := &fakeT{TB: t}
+	h := NewTHandler(ft, nil).AllowErrors("connection reset")
+	l := slog.New(h)
+
+	l.Error("connection reset by peer")
+	l.Error("disk full")
+
+	if len(ft.failures) != 1 || !strings.Contains(ft.failures[0], "disk full") {
+		t.Errorf("expected one failure for the unexpected error, got %v", ft.failures)
+	}
+	if len(ft.logs) != 1 || !strings.Contains(ft.logs[0], "connection reset") {
+		t.Errorf("allowed error should be logged, got %v", ft.logs)
+	}
+}
+
+func TestTHandler_DropsAfterTest(t *testing.T) {
+	var l *slog.Logger
+	t.Run("inner", func(t *testing.T) {
+		l = TLogger(t, nil)
+	})
+	// Would panic if the record reached the finished test
+	l.Error("late record")
+}
+
+func TestTHandler_VerboseOutput(t *testing.T) {
+	if os.Getenv("LOGTEST_VERBOSE_CHILD") != "" {
+		_, _, line, _ := runtime.Caller(0)
+		TLogger(t, slog.LevelInfo).Info("from the child", "line", line+1)
+		return
+	}
+	t.Parallel()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTHandler_VerboseOutput$", "-test.v")
+	cmd.Env = append(os.Environ(), "LOGTEST_VERBOSE_CHILD=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("child test failed: %v\n%s", err, out)
+	}
+
+	// The caller of the logging call is in the message, the prefix of go test
+	// points into the handler
+	for _, l := range strings.Split(string(out), "\n") {
+		if _, line, ok := strings.Cut(l, "from the child | line="); ok {
+			caller := fmt.Sprintf("[thandler_test.go:%s] from the child", line)
+			if !strings.HasPrefix(strings.TrimSpace(l), "thandler.go:") || !strings.Contains(l, caller) {
+				t.Errorf("output line %q should have the prefix thandler.go: and contain %q", l, caller)
+			}
+			return
+		}
+	}
+	t.Errorf("no log line in the output:\n%s", out)
+}
@@ -43,7 +43,8 @@ func main() {
 	log.Info("  LOG_LEVEL=debug        - set log level (trace, debug, info, warn, error, fatal, panic)")
 	log.Info("  LOG_TIMEZONE=UTC       - set timezone for timestamps")
 	log.Info("  LOG_DIRECTORY=logs     - set custom directory for log files (default: data/logs)")
-	log.Info("  LOG_RETENTION_DAYS=30  - number of days to keep log files (default: 30)")
+	log.Info("  LOG_ROTATION=hourly    - start new files daily, hourly, weekly or every N minutes")
+	log.Info("  LOG_RETENTION=72h      - how long to keep log files (default: 30d)")
 	log.Info("  LOG_SHOW_CALLER=true   - show file:line where log was called")
 
 	log.Println("\n=== End of Examples ===")
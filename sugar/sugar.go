@@ -0,0 +1,131 @@
+// Package sugar provides a logger with the method set of zap's
+// SugaredLogger, writing through the handlers of the log package. It lets
+// code written against zap's sugared API log without depending on zap:
+//
+//	logger := sugar.New().Named("billing").With("tenant", id)
+//	logger.Infow("invoice sent", "invoice", inv.ID)
+//	logger.Errorf("retry %d failed", n)
+package sugar
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/tsisar/extended-log-go/log"
+)
+
+// NameKey is the attribute holding the name set with Named.
+const NameKey = "logger"
+
+// Logger is a zap-style sugared logger. The zero value is ready to use.
+type Logger struct {
+	name string
+	args []any
+}
+
+// New returns a Logger.
+func New() *Logger {
+	return &Logger{}
+}
+
+// With returns a logger adding the key-value pairs to every record.
+func (s *Logger) With(keysAndValues ...any) *Logger {
+	s2 := *s
+	s2.args = append(append([]any(nil), s.args...), keysAndValues...)
+	return &s2
+}
+
+// Named returns a logger with name appended to the logger name, separated by a dot.
+func (s *Logger) Named(name string) *Logger {
+	s2 := *s
+	if s.name != "" {
+		name = s.name + "." + name
+	}
+	s2.name = name
+	return &s2
+}
+
+// Sync exists for compatibility with zap; records are written synchronously.
+func (s *Logger) Sync() error {
+	return nil
+}
+
+func (s *Logger) log(level slog.Level, msg string, keysAndValues []any) {
+	ctx := context.Background()
+	l := log.Logger()
+	if !l.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip: runtime.Callers, log, public method
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	if s.name != "" {
+		r.AddAttrs(slog.String(NameKey, s.name))
+	}
+	r.Add(s.args...)
+	r.Add(keysAndValues...)
+	_ = l.Handler().Handle(ctx, r)
+}
+
+// Debug logs the arguments, formatted like fmt.Sprint, at Debug level.
+func (s *Logger) Debug(args ...any) {
+	s.log(slog.LevelDebug, fmt.Sprint(args...), nil)
+}
+
+// Debugf logs a formatted message at Debug level.
+func (s *Logger) Debugf(format string, args ...any) {
+	s.log(slog.LevelDebug, fmt.Sprintf(format, args...), nil)
+}
+
+// Debugw logs a message with key-value pairs at Debug level.
+func (s *Logger) Debugw(msg string, keysAndValues ...any) {
+	s.log(slog.LevelDebug, msg, keysAndValues)
+}
+
+// Info logs the arguments, formatted like fmt.Sprint, at Info level.
+func (s *Logger) Info(args ...any) {
+	s.log(slog.LevelInfo, fmt.Sprint(args...), nil)
+}
+
+// Infof logs a formatted message at Info level.
+func (s *Logger) Infof(format string, args ...any) {
+	s.log(slog.LevelInfo, fmt.Sprintf(format, args...), nil)
+}
+
+// Infow logs a message with key-value pairs at Info level.
+func (s *Logger) Infow(msg string, keysAndValues ...any) {
+	s.log(slog.LevelInfo, msg, keysAndValues)
+}
+
+// Warn logs the arguments, formatted like fmt.Sprint, at Warn level.
+func (s *Logger) Warn(args ...any) {
+	s.log(slog.LevelWarn, fmt.Sprint(args...), nil)
+}
+
+// Warnf logs a formatted message at Warn level.
+func (s *Logger) Warnf(format string, args ...any) {
+	s.log(slog.LevelWarn, fmt.Sprintf(format, args...), nil)
+}
+
+// Warnw logs a message with key-value pairs at Warn level.
+func (s *Logger) Warnw(msg string, keysAndValues ...any) {
+	s.log(slog.LevelWarn, msg, keysAndValues)
+}
+
+// Error logs the arguments, formatted like fmt.Sprint, at Error level.
+func (s *Logger) Error(args ...any) {
+	s.log(slog.LevelError, fmt.Sprint(args...), nil)
+}
+
+// Errorf logs a formatted message at Error level.
+func (s *Logger) Errorf(format string, args ...any) {
+	s.log(slog.LevelError, fmt.Sprintf(format, args...), nil)
+}
+
+// Errorw logs a message with key-value pairs at Error level.
+func (s *Logger) Errorw(msg string, keysAndValues ...any) {
+	s.log(slog.LevelError, msg, keysAndValues)
+}
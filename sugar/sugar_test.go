@@ -0,0 +1,27 @@
+package sugar
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tsisar/extended-log-go/logtest"
+)
+
+func TestLogger(t *testing.T) {
+	rec := logtest.CaptureGlobal(t)
+	l := New().Named("billing").Named("invoices").With("tenant", "acme")
+
+	l.Infow("invoice sent", "invoice", 42)
+	l.Errorf("retry %d failed", 3)
+	l.Debug("cache ", "warm")
+
+	rec.HasEntry(slog.LevelInfo, "invoice sent", NameKey, "billing.invoices", "tenant", "acme", "invoice", 42)
+	rec.HasEntry(slog.LevelError, "retry 3 failed", "tenant", "acme")
+	rec.HasEntry(slog.LevelDebug, "cache warm")
+	for _, e := range rec.Entries() {
+		if !strings.HasPrefix(e.Caller, "sugar_test.go:") {
+			t.Errorf("%q: caller = %q, want sugar_test.go", e.Message, e.Caller)
+		}
+	}
+}